@@ -4,8 +4,15 @@ const TEST2 string = `
 ---
 name: test2
 
+when:
+  input GREETING = hello: {}
+  input LOUD bool = false: {}
+
 steps:
   - name: test2
     task: docker
     command: { env: COMMAND }
+    params:
+      GREETING: { env: GREETING }
+      LOUD: { env: LOUD }
 `
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	approvalGroup   string
+	approvalComment string
+	approvalsJSON   bool
+)
+
+func init() {
+	approvalsCmd.Flags().BoolVar(&approvalsJSON, "json", false, "print the pending approvals as JSON")
+	rootCmd.AddCommand(approvalsCmd)
+
+	for _, cmd := range []*cobra.Command{approveCmd, rejectCmd} {
+		cmd.Flags().StringVarP(&approvalGroup, "group", "g", "", "worker group of the activity")
+		cmd.Flags().StringVarP(&approvalComment, "comment", "m", "", "comment recorded with the decision")
+		rootCmd.AddCommand(cmd)
+	}
+
+	parkedCmd.Flags().BoolVar(&approvalsJSON, "json", false, "print the parked triggers as JSON")
+	rootCmd.AddCommand(parkedCmd)
+
+	for _, cmd := range []*cobra.Command{approveTriggerCmd, discardTriggerCmd} {
+		cmd.Flags().StringVarP(&approvalComment, "comment", "m", "", "comment recorded with the decision")
+		rootCmd.AddCommand(cmd)
+	}
+}
+
+var approvalsCmd = &cobra.Command{
+	Use:   "approvals",
+	Short: "List the pending approvals which may be decided with the approval token",
+
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		approvals, err := newClient(approvalToken).Approvals(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("fetching approvals failed - %s", err)
+		}
+
+		if approvalsJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(approvals)
+		}
+
+		if len(approvals) == 0 {
+			fmt.Println("no pending approvals")
+			return nil
+		}
+
+		for _, approval := range approvals {
+			fmt.Printf("%s (group %s): step %s of pipeline %s, waiting since %s\n", approval.Activity, approval.WorkerGroup, approval.Name, approval.Pipeline, approval.Since.Format(time.RFC3339))
+			if approval.Message != "" {
+				fmt.Printf("  %s\n", approval.Message)
+			}
+			if len(approval.Approvers) > 0 {
+				fmt.Printf("  approvers: %s\n", strings.Join(approval.Approvers, ", "))
+			}
+		}
+
+		return nil
+	},
+}
+
+var approveCmd = &cobra.Command{
+	Use:   "approve activity",
+	Short: "Approve the pending approval step of an activity",
+
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := newClient(approvalToken).Approve(cmd.Context(), approvalGroup, args[0], approvalComment)
+		if err != nil {
+			return fmt.Errorf("approving failed - %s", err)
+		}
+		return nil
+	},
+}
+
+var rejectCmd = &cobra.Command{
+	Use:   "reject activity",
+	Short: "Reject the pending approval step of an activity, so that the step fails",
+
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := newClient(approvalToken).Reject(cmd.Context(), approvalGroup, args[0], approvalComment)
+		if err != nil {
+			return fmt.Errorf("rejecting failed - %s", err)
+		}
+		return nil
+	},
+}
+
+var parkedCmd = &cobra.Command{
+	Use:   "parked",
+	Short: "List the parked triggers which may be decided with the approval token",
+
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parked, err := newClient(approvalToken).ParkedTriggers(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("fetching parked triggers failed - %s", err)
+		}
+
+		if approvalsJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(parked)
+		}
+
+		if len(parked) == 0 {
+			fmt.Println("no parked triggers")
+			return nil
+		}
+
+		for _, trigger := range parked {
+			fmt.Printf("%s: pipelines %s, waiting since %s\n", trigger.ID, strings.Join(trigger.Pipelines, ", "), trigger.Since.Format(time.RFC3339))
+
+			keys := make([]string, 0, len(trigger.Trigger))
+			for key := range trigger.Trigger {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Printf("  %s=%s\n", key, trigger.Trigger[key])
+			}
+
+			if len(trigger.Approvers) > 0 {
+				fmt.Printf("  approvers: %s\n", strings.Join(trigger.Approvers, ", "))
+			}
+		}
+
+		return nil
+	},
+}
+
+var approveTriggerCmd = &cobra.Command{
+	Use:   "approve-trigger id",
+	Short: "Approve a parked trigger, so that its pipelines are enqueued",
+
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := newClient(approvalToken).ApproveTrigger(cmd.Context(), args[0], approvalComment)
+		if err != nil {
+			return fmt.Errorf("approving trigger failed - %s", err)
+		}
+		return nil
+	},
+}
+
+var discardTriggerCmd = &cobra.Command{
+	Use:   "discard-trigger id",
+	Short: "Discard a parked trigger without running its pipelines",
+
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := newClient(approvalToken).DiscardTrigger(cmd.Context(), args[0], approvalComment)
+		if err != nil {
+			return fmt.Errorf("discarding trigger failed - %s", err)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	artifactsJSON  bool
+	artifactOutput string
+)
+
+func init() {
+	artifactsCmd.Flags().BoolVar(&artifactsJSON, "json", false, "print the artifacts as JSON")
+	rootCmd.AddCommand(artifactsCmd)
+
+	downloadArtifactCmd.Flags().StringVarP(&artifactOutput, "output", "o", "", "output file, \"-\" writes to stdout (default is the artifact name)")
+	rootCmd.AddCommand(downloadArtifactCmd)
+}
+
+var artifactsCmd = &cobra.Command{
+	Use:   "artifacts activity",
+	Short: "List the artifacts published by an activity",
+
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := newClient(cliToken).Artifacts(cmd.Context(), args[0])
+		if err != nil {
+			return fmt.Errorf("fetching artifacts failed - %s", err)
+		}
+
+		if artifactsJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(result)
+		}
+
+		fmt.Printf("%s (group %s): pipeline %s\n", result.Activity, result.WorkerGroup, result.Pipeline)
+		if len(result.Artifacts) == 0 {
+			fmt.Println("  no artifacts")
+			return nil
+		}
+
+		for _, artifact := range result.Artifacts {
+			fmt.Printf("  %s: %v bytes, published %s\n", artifact.Name, artifact.Size, artifact.Created.Format(time.RFC3339))
+		}
+
+		return nil
+	},
+}
+
+var downloadArtifactCmd = &cobra.Command{
+	Use:   "download-artifact activity name",
+	Short: "Download an artifact published by an activity",
+
+	Args: cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		content, err := newClient(cliToken).DownloadArtifact(cmd.Context(), args[0], args[1])
+		if err != nil {
+			return fmt.Errorf("downloading artifact failed - %s", err)
+		}
+		defer content.Close()
+
+		output := artifactOutput
+		if output == "" {
+			output = args[1]
+		}
+
+		if output == "-" {
+			_, err = io.Copy(os.Stdout, content)
+			if err != nil {
+				return fmt.Errorf("downloading artifact failed - %s", err)
+			}
+			return nil
+		}
+
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("creating output file failed - %s", err)
+		}
+
+		_, err = io.Copy(file, content)
+		closeErr := file.Close()
+		if err != nil {
+			return fmt.Errorf("downloading artifact failed - %s", err)
+		}
+		if closeErr != nil {
+			return fmt.Errorf("writing output file failed - %s", closeErr)
+		}
+
+		fmt.Fprintf(os.Stderr, "downloaded artifact %s to %s\n", args[1], output)
+		return nil
+	},
+}
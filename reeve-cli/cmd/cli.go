@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+	rootCmd.AddCommand(callCmd)
+}
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "List the CLI methods provided by plugins",
+
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		usage, err := newClient(cliToken).CLIUsage(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("fetching CLI usage failed - %s", err)
+		}
+
+		targets := make([]string, 0, len(usage))
+		for target := range usage {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+
+		for _, target := range targets {
+			methods := make([]string, 0, len(usage[target]))
+			for method := range usage[target] {
+				methods = append(methods, method)
+			}
+			sort.Strings(methods)
+
+			fmt.Println(target)
+			for _, method := range methods {
+				fmt.Printf("  %s: %s\n", method, usage[target][method])
+			}
+		}
+
+		return nil
+	},
+}
+
+var callCmd = &cobra.Command{
+	Use:   "call target method [args...]",
+	Short: "Call a CLI method of a plugin",
+
+	Args: cobra.MinimumNArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := newClient(cliToken).CallCLIMethod(cmd.Context(), args[0], args[1], args[2:])
+		if err != nil {
+			return fmt.Errorf("calling CLI method failed - %s", err)
+		}
+
+		fmt.Print(result)
+		return nil
+	},
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(healthCmd)
+	rootCmd.AddCommand(readyCmd)
+}
+
+var healthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Print the health report of the server, fails if the server is not alive",
+
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := newClient("").Health(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("fetching health report failed - %s", err)
+		}
+
+		return printReport(report, report.Live)
+	},
+}
+
+var readyCmd = &cobra.Command{
+	Use:   "ready",
+	Short: "Print the health report of the server, fails if the server is not ready",
+
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := newClient("").Ready(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("fetching health report failed - %s", err)
+		}
+
+		return printReport(report, report.Ready)
+	},
+}
+
+func printReport(report any, ok bool) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(report)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+	return nil
+}
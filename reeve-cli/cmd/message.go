@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var messageOptions []string
+
+func init() {
+	messageCmd.Flags().StringArrayVarP(&messageOptions, "option", "o", nil, "message option key=value, may be repeated")
+	rootCmd.AddCommand(messageCmd)
+}
+
+var messageCmd = &cobra.Command{
+	Use:   "message target [data|-]",
+	Short: "Send a message to a message plugin, * sends it to all plugins",
+	Long:  "Send a message to a message plugin, * sends it to all plugins.\nIf data is -, it is read from stdin.",
+
+	Args: cobra.RangeArgs(1, 2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options := make(map[string]string, len(messageOptions))
+		for _, option := range messageOptions {
+			key, value, ok := strings.Cut(option, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid message option %s, expected key=value", option)
+			}
+			options[key] = value
+		}
+
+		var data io.Reader
+		if len(args) > 1 {
+			if args[1] == "-" {
+				data = os.Stdin
+			} else {
+				data = strings.NewReader(args[1])
+			}
+		}
+
+		err := newClient(messageToken).SendMessage(cmd.Context(), args[0], options, data)
+		if err != nil {
+			return fmt.Errorf("sending message failed - %s", err)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/reeveci/reeve/reeve-client"
+	"github.com/spf13/cobra"
+)
+
+var programName = os.Args[0]
+
+var (
+	serverUrl     string
+	cliToken      string
+	messageToken  string
+	approvalToken string
+	insecure      bool
+)
+
+var rootCmd = &cobra.Command{
+	Use:   programName,
+	Short: "Reeve CI / CD - Command Line Interface",
+
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&serverUrl, "server", "s", getEnvDef("REEVE_SERVER_API", "http://localhost:9080"), "server URL (REEVE_SERVER_API)")
+	rootCmd.PersistentFlags().StringVar(&cliToken, "token", os.Getenv("REEVE_CLI_TOKEN"), "CLI token (REEVE_CLI_TOKEN)")
+	rootCmd.PersistentFlags().StringVar(&messageToken, "message-token", os.Getenv("REEVE_MESSAGE_TOKEN"), "message token (REEVE_MESSAGE_TOKEN)")
+	rootCmd.PersistentFlags().StringVar(&approvalToken, "approval-token", os.Getenv("REEVE_APPROVAL_TOKEN"), "approval token (REEVE_APPROVAL_TOKEN)")
+	rootCmd.PersistentFlags().BoolVarP(&insecure, "insecure", "k", false, "skip TLS certificate verification")
+}
+
+func getEnvDef(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
+
+func newClient(token string) *client.Client {
+	options := []client.Option{}
+
+	if token != "" {
+		options = append(options, client.WithToken(token))
+	}
+
+	if insecure {
+		options = append(options, client.WithHTTPClient(&http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		}))
+	}
+
+	return client.New(serverUrl, options...)
+}
+
+func Execute(buildVersion string) {
+	rootCmd.Version = buildVersion
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
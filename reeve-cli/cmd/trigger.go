@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	triggerInputs []string
+	explainJSON   bool
+)
+
+func init() {
+	triggerCmd.Flags().StringArrayVarP(&triggerInputs, "input", "i", nil, "pipeline input NAME=value, may be repeated")
+	rootCmd.AddCommand(triggerCmd)
+
+	explainCmd.Flags().StringArrayVarP(&triggerInputs, "input", "i", nil, "pipeline input NAME=value, may be repeated")
+	explainCmd.Flags().BoolVar(&explainJSON, "json", false, "print the full explanation including the redacted pipeline definitions as JSON")
+	rootCmd.AddCommand(explainCmd)
+}
+
+// parseTrigger builds a trigger from key=value arguments and the --input flags.
+func parseTrigger(args []string) (schema.Trigger, error) {
+	trigger, err := parseKeyValues(args, "trigger value")
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, err := parseKeyValues(triggerInputs, "input")
+	if err != nil {
+		return nil, err
+	}
+
+	return client.WithInputs(trigger, inputs), nil
+}
+
+func parseKeyValues(args []string, kind string) (map[string]string, error) {
+	result := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid %s %s, expected key=value", kind, arg)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+var triggerCmd = &cobra.Command{
+	Use:   "trigger key=value...",
+	Short: "Run the pipelines discovered for a trigger, e.g. type=commit url=... branch=main",
+
+	Args: cobra.MinimumNArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		trigger, err := parseTrigger(args)
+		if err != nil {
+			return err
+		}
+
+		err = newClient(cliToken).Trigger(cmd.Context(), trigger)
+		if err != nil {
+			return fmt.Errorf("sending trigger failed - %s", err)
+		}
+
+		return nil
+	},
+}
+
+var explainCmd = &cobra.Command{
+	Use:   "explain key=value...",
+	Short: "Explain which pipelines a trigger would start and why others are skipped, without starting any pipelines",
+
+	Args: cobra.MinimumNArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		trigger, err := parseTrigger(args)
+		if err != nil {
+			return err
+		}
+
+		explanation, err := newClient(cliToken).ExplainTrigger(cmd.Context(), trigger)
+		if err != nil {
+			return fmt.Errorf("explaining trigger failed - %s", err)
+		}
+
+		if explainJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(explanation)
+		}
+
+		if explanation.ApprovalRequired {
+			if len(explanation.Approvers) > 0 {
+				fmt.Printf("trigger requires approval by %s before pipelines are enqueued\n", strings.Join(explanation.Approvers, ", "))
+			} else {
+				fmt.Println("trigger requires approval before pipelines are enqueued")
+			}
+		}
+
+		for _, pluginError := range explanation.Errors {
+			fmt.Printf("plugin %s failed to %s - %s\n", pluginError.Plugin, pluginError.Stage, pluginError.Error)
+		}
+
+		if len(explanation.Pipelines) == 0 {
+			fmt.Println("no pipelines discovered")
+			return nil
+		}
+
+		for _, pipeline := range explanation.Pipelines {
+			fmt.Printf("%s (%s): %s", pipeline.Pipeline, pipeline.Plugin, pipeline.Result)
+			if len(pipeline.WorkerGroups) > 0 {
+				fmt.Printf(" in %s", strings.Join(pipeline.WorkerGroups, ", "))
+			}
+			if pipeline.Reason != "" {
+				fmt.Printf(" - %s", pipeline.Reason)
+			}
+			fmt.Println()
+
+			names := make([]string, 0, len(pipeline.Inputs))
+			for name := range pipeline.Inputs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("  input %s=%s\n", name, pipeline.Inputs[name])
+			}
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,15 @@
+module github.com/reeveci/reeve/reeve-cli
+
+go 1.24.3
+
+require (
+	github.com/reeveci/reeve-lib v1.3.0
+	github.com/reeveci/reeve/reeve-client v1.7.0
+	github.com/spf13/cobra v1.9.1
+)
+
+require (
+	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/spf13/pflag v1.0.6 // indirect
+)
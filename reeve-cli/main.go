@@ -0,0 +1,9 @@
+package main
+
+import "github.com/reeveci/reeve/reeve-cli/cmd"
+
+var buildVersion = "development"
+
+func main() {
+	cmd.Execute(buildVersion)
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// ApprovalRequest describes an approval step a runner is waiting for.
+type ApprovalRequest struct {
+	Step    int    `json:"step"`
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
+	// Approvers contains the names of the approval tokens which may decide, any approval token may decide if empty.
+	Approvers []string `json:"approvers,omitempty"`
+}
+
+type ApprovalDecision struct {
+	Approved bool   `json:"approved"`
+	By       string `json:"by"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// PendingApproval is an approval step of a running pipeline waiting for a decision.
+type PendingApproval struct {
+	WorkerGroup string `json:"workerGroup"`
+	Activity    string `json:"activity"`
+	Pipeline    string `json:"pipeline"`
+	ApprovalRequest
+	Since time.Time `json:"since"`
+}
+
+type approvalComment struct {
+	Comment string `json:"comment"`
+}
+
+// WorkerApproval pauses an activity for an approval step and blocks until the step has been decided.
+// The activity timeout is suspended while the request is open.
+func (c *Client) WorkerApproval(ctx context.Context, group, activity string, approval ApprovalRequest) (*ApprovalDecision, error) {
+	body, err := jsonBody(approval)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, PATH_PREFIX+"/worker/approval", activityQuery(group, activity), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result ApprovalDecision
+	_, err = c.doJSON(req, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Approvals lists the pending approvals which may be decided by the authenticated approval token.
+func (c *Client) Approvals(ctx context.Context) ([]PendingApproval, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, PATH_PREFIX+"/approval", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []PendingApproval
+	_, err = c.doJSON(req, &result)
+	return result, err
+}
+
+// Approve approves the pending approval step of an activity, so that the pipeline continues.
+// Requests must be authenticated using an approval token.
+func (c *Client) Approve(ctx context.Context, group, activity, comment string) error {
+	return c.decideApproval(ctx, "/approval/approve", activityQuery(group, activity), comment)
+}
+
+// Reject rejects the pending approval step of an activity, so that the step fails.
+// Requests must be authenticated using an approval token.
+func (c *Client) Reject(ctx context.Context, group, activity, comment string) error {
+	return c.decideApproval(ctx, "/approval/reject", activityQuery(group, activity), comment)
+}
+
+func (c *Client) decideApproval(ctx context.Context, path string, query url.Values, comment string) error {
+	body, err := jsonBody(approvalComment{Comment: comment})
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, PATH_PREFIX+path, query, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
+
+// ParkedTrigger is a trigger matching a trigger approval rule, its pipelines are not enqueued before it has been approved.
+type ParkedTrigger struct {
+	ID      string         `json:"id"`
+	Trigger schema.Trigger `json:"trigger"`
+	Since   time.Time      `json:"since"`
+	// Approvers contains the names of the approval tokens which may decide, any approval token may decide if empty.
+	Approvers []string `json:"approvers,omitempty"`
+	// Pipelines contains the names of the pipelines discovered for the trigger.
+	Pipelines []string `json:"pipelines"`
+}
+
+// ParkedTriggers lists the parked triggers which may be decided by the authenticated approval token.
+func (c *Client) ParkedTriggers(ctx context.Context) ([]ParkedTrigger, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, PATH_PREFIX+"/approval/trigger", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []ParkedTrigger
+	_, err = c.doJSON(req, &result)
+	return result, err
+}
+
+// ApproveTrigger approves a parked trigger, so that its pipelines are enqueued with resolved secrets.
+// Requests must be authenticated using an approval token.
+func (c *Client) ApproveTrigger(ctx context.Context, id, comment string) error {
+	return c.decideApproval(ctx, "/approval/trigger/approve", url.Values{"id": {id}}, comment)
+}
+
+// DiscardTrigger discards a parked trigger without running its pipelines.
+// Requests must be authenticated using an approval token.
+func (c *Client) DiscardTrigger(ctx context.Context, id, comment string) error {
+	return c.decideApproval(ctx, "/approval/trigger/discard", url.Values{"id": {id}}, comment)
+}
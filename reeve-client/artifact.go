@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Artifact is a file published by a pipeline, usually a gzipped tar archive created by reeve-tools.
+type Artifact struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+}
+
+// ActivityArtifacts contains all artifacts of an activity.
+type ActivityArtifacts struct {
+	WorkerGroup string     `json:"workerGroup"`
+	Activity    string     `json:"activity"`
+	Pipeline    string     `json:"pipeline"`
+	Artifacts   []Artifact `json:"artifacts"`
+}
+
+// WorkerArtifact uploads an artifact of a running activity, replacing any existing artifact with the same name.
+// Worker requests must be authenticated using a worker token.
+func (c *Client) WorkerArtifact(ctx context.Context, group, activity, name string, content io.Reader) (*Artifact, error) {
+	query := activityQuery(group, activity)
+	query.Set("name", name)
+
+	req, err := c.newRequest(ctx, http.MethodPost, PATH_PREFIX+"/worker/artifact", query, content)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	var result Artifact
+	_, err = c.doJSON(req, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Artifacts lists the artifacts of an activity.
+// Requests must be authenticated using a CLI token.
+func (c *Client) Artifacts(ctx context.Context, activity string) (*ActivityArtifacts, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, PATH_PREFIX+"/artifact", url.Values{"activity": {activity}}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ActivityArtifacts
+	_, err = c.doJSON(req, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// DownloadArtifact opens an artifact of an activity for reading, the caller must close the returned reader.
+// Requests must be authenticated using a CLI token.
+func (c *Client) DownloadArtifact(ctx context.Context, activity, name string) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, PATH_PREFIX+"/artifact/download", url.Values{"activity": {activity}, "name": {name}}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
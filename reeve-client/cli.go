@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// CLIUsage returns the descriptions of all CLI methods by plugin and method name.
+// CLI requests must be authenticated using a CLI token.
+func (c *Client) CLIUsage(ctx context.Context) (map[string]map[string]string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, PATH_PREFIX+"/cli", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]map[string]string
+	_, err = c.doJSON(req, &result)
+	return result, err
+}
+
+// CallCLIMethod calls a CLI method of the plugin target and returns its output.
+func (c *Client) CallCLIMethod(ctx context.Context, target, method string, args []string) (string, error) {
+	if args == nil {
+		args = []string{}
+	}
+
+	body, err := jsonBody(args)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, PATH_PREFIX+"/cli", url.Values{"target": {target}, "method": {method}}, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading response failed - %s", err)
+	}
+
+	return string(result), nil
+}
@@ -0,0 +1,177 @@
+// Package client provides access to the reeve server API as described in reeve-server/api/openapi.yaml.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const PATH_PREFIX = "/api/v1"
+
+// RequestEditor may modify requests before they are sent, e.g. in order to add tracing headers.
+type RequestEditor func(ctx context.Context, req *http.Request) error
+
+type Client struct {
+	// Server is the base URL of the server, e.g. http://localhost:9080
+	Server     string
+	HTTPClient *http.Client
+
+	AuthHeader string
+	Auth       string
+
+	RequestEditors []RequestEditor
+}
+
+type Option func(c *Client)
+
+// WithHTTPClient replaces http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.HTTPClient = httpClient
+	}
+}
+
+// WithAuth sends value in the given header with every request.
+func WithAuth(header, value string) Option {
+	return func(c *Client) {
+		c.AuthHeader = header
+		c.Auth = value
+	}
+}
+
+// WithToken authenticates every request using a bearer token.
+func WithToken(token string) Option {
+	return WithAuth("Authorization", "Bearer "+token)
+}
+
+func WithRequestEditor(editor RequestEditor) Option {
+	return func(c *Client) {
+		c.RequestEditors = append(c.RequestEditors, editor)
+	}
+}
+
+func New(server string, options ...Option) *Client {
+	c := &Client{
+		Server:     strings.TrimSuffix(server, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Error is returned for responses with an unexpected status code.
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is set if the server asked to retry the request later.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("status %v - %s", e.StatusCode, e.Message)
+}
+
+// Temporary returns true if the request may succeed when being retried.
+func (e *Error) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := c.Server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP request failed - %s", err)
+	}
+
+	if c.AuthHeader != "" {
+		req.Header.Set(c.AuthHeader, c.Auth)
+	}
+
+	for _, editor := range c.RequestEditors {
+		err = editor(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// do sends req and returns the response if the status is 200 OK.
+// The response body must be closed by the caller.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+
+	return resp, nil
+}
+
+func responseError(resp *http.Response) error {
+	message, _ := io.ReadAll(resp.Body)
+
+	result := &Error{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(message)),
+	}
+
+	if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		result.RetryAfter = time.Duration(retryAfter) * time.Second
+	}
+
+	return result
+}
+
+func (c *Client) doJSON(req *http.Request, result any) (http.Header, error) {
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	return resp.Header, decodeJSON(resp, result)
+}
+
+func decodeJSON(resp *http.Response, result any) error {
+	if resp.Header.Get("Content-Type") != "application/json" {
+		return fmt.Errorf("Content-Type header is not application/json")
+	}
+
+	err := json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		return fmt.Errorf("received invalid response - %s", err)
+	}
+
+	return nil
+}
+
+func jsonBody(value any) (io.Reader, error) {
+	buffer := new(bytes.Buffer)
+	err := json.NewEncoder(buffer).Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request body failed - %s", err)
+	}
+	return buffer, nil
+}
@@ -0,0 +1,7 @@
+module github.com/reeveci/reeve/reeve-client
+
+go 1.23.0
+
+require github.com/reeveci/reeve-lib v1.3.0
+
+require github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type HealthReport struct {
+	Live  bool `json:"live"`
+	Ready bool `json:"ready"`
+
+	Phase   string                     `json:"phase"`
+	Plugins map[string]ComponentHealth `json:"plugins,omitempty"`
+	Loops   map[string]ComponentHealth `json:"loops,omitempty"`
+	Queues  QueueHealth                `json:"queues"`
+}
+
+type ComponentHealth struct {
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	BusySince *time.Time `json:"busySince,omitempty"`
+}
+
+type QueueHealth struct {
+	Messages QueueStatus                  `json:"messages"`
+	Triggers QueueStatus                  `json:"triggers"`
+	Notify   QueueStatus                  `json:"notify"`
+	Plugins  map[string]QueueStatus       `json:"plugins,omitempty"`
+	Workers  map[string]WorkerQueueStatus `json:"workers"`
+}
+
+type QueueStatus struct {
+	Count    uint `json:"count"`
+	Capacity uint `json:"capacity,omitempty"`
+	Full     bool `json:"full,omitempty"`
+}
+
+type WorkerQueueStatus struct {
+	Enqueued    uint `json:"enqueued"`
+	Running     int  `json:"running"`
+	Concurrency int  `json:"concurrency,omitempty"`
+}
+
+// Health returns the health report of the server, Live reports whether the check succeeded.
+func (c *Client) Health(ctx context.Context) (*HealthReport, error) {
+	return c.healthReport(ctx, "/healthz")
+}
+
+// Ready returns the health report of the server, Ready reports whether the check succeeded.
+func (c *Client) Ready(ctx context.Context) (*HealthReport, error) {
+	return c.healthReport(ctx, "/readyz")
+}
+
+func (c *Client) healthReport(ctx context.Context, path string) (*HealthReport, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	// failing checks are answered with 503 and a report
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, responseError(resp)
+	}
+
+	var result HealthReport
+	err = decodeJSON(resp, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
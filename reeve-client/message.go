@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// BROADCAST_TARGET sends a message to all message plugins.
+const BROADCAST_TARGET = "*"
+
+// SendMessage sends data to the message plugin target, options are passed to the plugin as message options.
+// Message requests must be authenticated using a message token.
+func (c *Client) SendMessage(ctx context.Context, target string, options map[string]string, data io.Reader) error {
+	query := make(url.Values, len(options)+1)
+	for key, value := range options {
+		query.Set(key, value)
+	}
+	query.Set("target", target)
+
+	req, err := c.newRequest(ctx, http.MethodPost, PATH_PREFIX+"/message", query, data)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
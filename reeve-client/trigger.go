@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// INPUT_PREFIX marks trigger entries containing values for pipeline input parameters.
+const INPUT_PREFIX = "input "
+
+// WithInputs returns a copy of trigger containing the given values for pipeline input parameters.
+func WithInputs(trigger schema.Trigger, inputs map[string]string) schema.Trigger {
+	result := make(schema.Trigger, len(trigger)+len(inputs))
+	for key, value := range trigger {
+		result[key] = value
+	}
+	for name, value := range inputs {
+		result[INPUT_PREFIX+name] = value
+	}
+	return result
+}
+
+// Trigger enqueues trigger, so that the pipelines discovered for it are run.
+// Pipeline inputs may be passed using WithInputs. Requests must be authenticated using a CLI token.
+func (c *Client) Trigger(ctx context.Context, trigger schema.Trigger) error {
+	body, err := jsonBody(trigger)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, PATH_PREFIX+"/trigger", nil, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type TriggerExplanation struct {
+	Trigger   schema.Trigger     `json:"trigger"`
+	Pipelines []PipelineDecision `json:"pipelines"`
+	Errors    []PluginError      `json:"errors,omitempty"`
+	// ApprovalRequired is set if the trigger matches a trigger approval rule, so that its pipelines are parked until approved.
+	ApprovalRequired bool     `json:"approvalRequired,omitempty"`
+	Approvers        []string `json:"approvers,omitempty"`
+}
+
+type PipelineDecision struct {
+	Pipeline string `json:"pipeline"`
+	Plugin   string `json:"plugin"`
+	// Result is one of run, skipped or failed.
+	Result string `json:"result"`
+	Reason string `json:"reason,omitempty"`
+	// Condition is the key of the first condition which is not satisfied.
+	Condition    string   `json:"condition,omitempty"`
+	MissingEnv   []string `json:"missingEnv,omitempty"`
+	WorkerGroups []string `json:"workerGroups,omitempty"`
+	// Inputs contains the values of input parameters declared by the pipeline.
+	Inputs map[string]string `json:"inputs,omitempty"`
+	// Definition is the pipeline with resolved env, secret values are redacted.
+	Definition schema.Pipeline `json:"definition"`
+}
+
+type PluginError struct {
+	Plugin string `json:"plugin"`
+	Stage  string `json:"stage"`
+	Error  string `json:"error"`
+}
+
+// ExplainTrigger runs discovery, env resolution and condition checks for trigger without enqueueing any pipelines.
+// Requests must be authenticated using a CLI token.
+func (c *Client) ExplainTrigger(ctx context.Context, trigger schema.Trigger) (*TriggerExplanation, error) {
+	body, err := jsonBody(trigger)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, PATH_PREFIX+"/trigger/explain", nil, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result TriggerExplanation
+	_, err = c.doJSON(req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// WorkerQueueResponse contains the next pipeline of a worker group.
+// The pipeline is kept as is, so that it can be passed to the runner without losing any fields.
+type WorkerQueueResponse struct {
+	Contract string          `json:"contract"`
+	Activity string          `json:"activity"`
+	Pipeline json.RawMessage `json:"pipeline"`
+
+	// Header contains the response headers, including the trace context of the activity.
+	Header http.Header `json:"-"`
+}
+
+func groupQuery(group string) url.Values {
+	query := url.Values{}
+	if group != "" {
+		query.Set("group", group)
+	}
+	return query
+}
+
+func activityQuery(group, activity string) url.Values {
+	query := groupQuery(group)
+	query.Set("activity", activity)
+	return query
+}
+
+// WorkerQueue blocks until the next pipeline of a worker group is available.
+// The returned contract must be acknowledged using WorkerAck within the queue timeout of the worker group.
+// Worker requests must be authenticated using a worker token.
+func (c *Client) WorkerQueue(ctx context.Context, group string) (*WorkerQueueResponse, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, PATH_PREFIX+"/worker/queue", groupQuery(group), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result WorkerQueueResponse
+	result.Header, err = c.doJSON(req, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// WorkerAck acknowledges a contract, assigning the pipeline to the worker.
+func (c *Client) WorkerAck(ctx context.Context, group, contract string) error {
+	body, err := jsonBody(schema.WorkerAckRequest{Contract: contract})
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, PATH_PREFIX+"/worker/ack", groupQuery(group), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
+
+// WorkerLogsPosition returns the number of log bytes the server has received for an activity.
+func (c *Client) WorkerLogsPosition(ctx context.Context, group, activity string) (int64, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, PATH_PREFIX+"/worker/logs", activityQuery(group, activity), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var result schema.WorkerLogsPositionResponse
+	_, err = c.doJSON(req, &result)
+	return result.Position, err
+}
+
+// WriteWorkerLogs streams logs to the server until logs is exhausted.
+func (c *Client) WriteWorkerLogs(ctx context.Context, group, activity string, logs io.Reader) error {
+	req, err := c.newRequest(ctx, http.MethodPost, PATH_PREFIX+"/worker/logs", activityQuery(group, activity), logs)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
+
+// SendWorkerResult reports the result of a pipeline.
+func (c *Client) SendWorkerResult(ctx context.Context, group, activity string, result schema.PipelineResult) error {
+	body, err := jsonBody(result)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, PATH_PREFIX+"/worker/result", activityQuery(group, activity), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,18 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Write writes report as JSON, failing checks are answered with 503 Service Unavailable.
+func Write(res http.ResponseWriter, report any, ok bool) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	if ok {
+		res.WriteHeader(http.StatusOK)
+	} else {
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(res).Encode(report)
+}
@@ -0,0 +1,149 @@
+package mask
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const MASK = "*******"
+
+// MIN_VARIANT_LENGTH is the minimum length of derived variants of a secret, so that short common strings are not masked.
+const MIN_VARIANT_LENGTH = 6
+
+// Patterns returns secret and the variants of it which are long enough to be masked.
+func Patterns(secret string) []string {
+	if secret == "" {
+		return nil
+	}
+
+	patterns := []string{secret}
+	for _, variant := range secretVariants(secret) {
+		if len(variant) >= MIN_VARIANT_LENGTH {
+			patterns = append(patterns, variant)
+		}
+	}
+	return patterns
+}
+
+// NewReplacer returns a replacer replacing each of patterns with MASK.
+func NewReplacer(patterns map[string]bool) *strings.Replacer {
+	sorted := Sorted(patterns)
+	oldnew := make([]string, 0, 2*len(sorted))
+	for _, pattern := range sorted {
+		oldnew = append(oldnew, pattern, MASK)
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// Sorted returns patterns in the order in which they are replaced.
+// Longer patterns are replaced first, so that no parts of them are left when a shorter pattern is contained.
+func Sorted(patterns map[string]bool) []string {
+	sorted := make([]string, 0, len(patterns))
+	for pattern := range patterns {
+		sorted = append(sorted, pattern)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
+
+// secretVariants returns the forms in which a secret may show up in line based output.
+func secretVariants(secret string) []string {
+	values := []string{secret}
+
+	// output is filtered line by line with normalized line breaks, so multi-line secrets are masked as a whole across lines,
+	// parts of their lines are only masked on their own if they are unlikely to show up in other output, e.g. the body of a PEM block
+	if strings.ContainsAny(secret, "\r\n") {
+		values = append(values, strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(secret))
+		for _, line := range strings.FieldsFunc(secret, func(r rune) bool { return r == '\r' || r == '\n' }) {
+			for _, field := range strings.Fields(line) {
+				if isRandom(field) {
+					values = append(values, field)
+				}
+			}
+		}
+	}
+
+	var result []string
+	for i, value := range values {
+		if i > 0 {
+			result = append(result, value)
+		}
+		result = append(result, url.QueryEscape(value), url.PathEscape(value))
+		result = append(result, jsonEscape(value, true), jsonEscape(value, false))
+		result = append(result, base64Variants(value)...)
+	}
+	return result
+}
+
+// MIN_PART_LENGTH and MIN_PART_ENTROPY (bits per byte) limit the parts of multi-line secrets which are masked on their own.
+const (
+	MIN_PART_LENGTH  = 20
+	MIN_PART_ENTROPY = 4.0
+)
+
+// isRandom returns true if value looks like encoded key material or a token,
+// i.e. it is long enough, only contains base64 or hex characters and its bytes are distributed evenly enough.
+func isRandom(value string) bool {
+	if len(value) < MIN_PART_LENGTH {
+		return false
+	}
+
+	var counts [256]int
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("+/=_-", c) >= 0) {
+			return false
+		}
+		counts[c]++
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(len(value))
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy >= MIN_PART_ENTROPY
+}
+
+// jsonEscape returns value as JSON string without the surrounding quotes.
+func jsonEscape(value string, escapeHTML bool) string {
+	buffer := new(bytes.Buffer)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(escapeHTML)
+	encoder.Encode(value)
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(buffer.String()), `"`), `"`)
+}
+
+// base64Variants returns the base64 encoded forms of value for all three possible alignments within the encoded data.
+// Characters which depend on surrounding bytes are left out, so that value is found within larger encoded data as well.
+func base64Variants(value string) []string {
+	var result []string
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+		for offset := 0; offset < 3; offset++ {
+			data := append(make([]byte, offset), value...)
+			encoded := encoding.EncodeToString(data)
+
+			start := (offset*8 + 5) / 6
+			end := len(data) * 8 / 6
+			if start < end {
+				result = append(result, encoded[start:end])
+			}
+			if offset == 0 {
+				result = append(result, encoded)
+			}
+		}
+	}
+	return result
+}
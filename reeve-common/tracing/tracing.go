@@ -0,0 +1,215 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	EXPORTER_NONE = "none"
+	EXPORTER_OTLP = "otlp"
+	EXPORTER_FILE = "file"
+)
+
+const TRACER_NAME = "github.com/reeveci/reeve"
+
+// Attribute keys used for spans.
+const (
+	ATTR_WORKER_GROUP = attribute.Key("reeve.worker_group")
+	ATTR_ACTIVITY     = attribute.Key("reeve.activity")
+	ATTR_PIPELINE     = attribute.Key("reeve.pipeline")
+	ATTR_PLUGIN       = attribute.Key("reeve.plugin")
+	ATTR_TARGET       = attribute.Key("reeve.target")
+)
+
+// Environment variables passing the trace context from the worker to the runner and from the runner to tasks.
+const (
+	ENV_TRACEPARENT = "TRACEPARENT"
+	ENV_TRACESTATE  = "TRACESTATE"
+)
+
+var propagator = propagation.TraceContext{}
+
+func ValidExporter(exporter string) bool {
+	return exporter == "" || exporter == EXPORTER_NONE || exporter == EXPORTER_OTLP || exporter == EXPORTER_FILE
+}
+
+// Setup installs the global tracer provider for service.
+// The returned function flushes all pending spans and must be called before exiting.
+// If exporter is empty or none, tracing is disabled.
+func Setup(service, exporter, endpoint, file, version string) (func(context.Context) error, error) {
+	var spanExporter sdktrace.SpanExporter
+	var output *os.File
+	var err error
+
+	switch exporter {
+	case "", EXPORTER_NONE:
+		return func(context.Context) error { return nil }, nil
+
+	case EXPORTER_OTLP:
+		var options []otlptracehttp.Option
+		if endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		spanExporter, err = otlptracehttp.New(context.Background(), options...)
+		if err != nil {
+			return nil, fmt.Errorf("error setting up OTLP exporter - %s", err)
+		}
+
+	case EXPORTER_FILE:
+		if file == "" {
+			return nil, fmt.Errorf("missing trace file")
+		}
+		output, err = os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("error opening trace file - %s", err)
+		}
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(output))
+		if err != nil {
+			output.Close()
+			return nil, fmt.Errorf("error setting up file exporter - %s", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown exporter %s", exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(service),
+			semconv.ServiceVersion(version),
+		)),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		// the trace file may only be closed once all pending spans have been written
+		if output != nil {
+			if closeErr := output.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(TRACER_NAME)
+}
+
+// Start starts a span as child of the span in ctx.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// StartFrom starts a span as child of parent, which may belong to another goroutine or request.
+func StartFrom(parent trace.SpanContext, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Start(trace.ContextWithSpanContext(context.Background(), parent), name, attributes...)
+}
+
+// End ends span, recording err if it is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject propagates the trace of ctx in header.
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// InjectFrom writes the trace context of parent into header.
+func InjectFrom(parent trace.SpanContext, header http.Header) {
+	Inject(trace.ContextWithSpanContext(context.Background(), parent), header)
+}
+
+// Extract returns a context continuing the trace propagated in header.
+func Extract(header http.Header) context.Context {
+	return propagator.Extract(context.Background(), propagation.HeaderCarrier(header))
+}
+
+// Env returns environment variables propagating the trace of ctx to a child process.
+func Env(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+
+	var env []string
+	if value := carrier.Get("traceparent"); value != "" {
+		env = append(env, fmt.Sprintf("%s=%s", ENV_TRACEPARENT, value))
+	}
+	if value := carrier.Get("tracestate"); value != "" {
+		env = append(env, fmt.Sprintf("%s=%s", ENV_TRACESTATE, value))
+	}
+	return env
+}
+
+// FromEnv returns a context continuing the trace propagated by the parent process.
+func FromEnv() context.Context {
+	carrier := propagation.MapCarrier{}
+	if value := os.Getenv(ENV_TRACEPARENT); value != "" {
+		carrier.Set("traceparent", value)
+	}
+	if value := os.Getenv(ENV_TRACESTATE); value != "" {
+		carrier.Set("tracestate", value)
+	}
+	return propagator.Extract(context.Background(), carrier)
+}
+
+// Handler wraps an API handler in a server span, continuing traces propagated by the client.
+func Handler(name string, h http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		ctx := propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := Tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(req.Method),
+				semconv.URLPath(req.URL.Path),
+			),
+		)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: res, status: http.StatusOK}
+		h(recorder, req.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.status))
+		if recorder.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/reeveci/reeve/reeve-runner/runtime"
+)
+
+func HandleArtifact(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := req.URL.Query().Get("name")
+		if name == "" {
+			http.Error(res, `missing required query parameter "name"`, http.StatusBadRequest)
+			return
+		}
+		if !validArtifactName(name) {
+			http.Error(res, fmt.Sprintf("invalid artifact name %s", name), http.StatusBadRequest)
+			return
+		}
+
+		_, err := runtime.PublishArtifact(name, req.Body)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func validArtifactName(name string) bool {
+	return runtime.ValidArtifactName(name)
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/reeveci/reeve/reeve-common/healthcheck"
+	"github.com/reeveci/reeve/reeve-runner/runtime"
+)
+
+type healthResponse struct {
+	Phase    string `json:"phase"`
+	Canceled bool   `json:"canceled,omitempty"`
+}
+
+// HandleHealth reports the current phase of the pipeline execution, it fails once the pipeline has been canceled.
+func HandleHealth(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		canceled := !runtime.CheckHealth()
+		healthcheck.Write(res, healthResponse{Phase: runtime.Phase(), Canceled: canceled}, !canceled)
+	}
+}
+
+// HandleReady reports whether tasks are being executed, which is the case between setup and cleanup.
+func HandleReady(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		canceled := !runtime.CheckHealth()
+		healthcheck.Write(res, healthResponse{Phase: runtime.Phase(), Canceled: canceled}, !canceled && runtime.Executing())
+	}
+}
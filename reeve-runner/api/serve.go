@@ -12,6 +12,9 @@ func ServeAPI(runtime *runtime.Runtime) {
 	// check messageplugins before sending message into queue
 
 	http.HandleFunc("/api/v1/var", HandleVar(runtime))
+	http.HandleFunc("/api/v1/artifact", HandleArtifact(runtime))
+	http.HandleFunc("/healthz", HandleHealth(runtime))
+	http.HandleFunc("/readyz", HandleReady(runtime))
 
 	ServeHTTP(runtime)
 }
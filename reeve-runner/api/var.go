@@ -21,10 +21,7 @@ func HandleVar(runtime *runtime.Runtime) http.HandlerFunc {
 			}
 
 			v := q.Get("value")
-
-			runtime.VarLock.Lock()
-			runtime.Vars[key] = schema.Var(v)
-			runtime.VarLock.Unlock()
+			setVar(runtime, key, v, q.Get("secret") == "true")
 
 		case http.MethodPost:
 			q := req.URL.Query()
@@ -40,12 +37,21 @@ func HandleVar(runtime *runtime.Runtime) http.HandlerFunc {
 				return
 			}
 
-			runtime.VarLock.Lock()
-			runtime.Vars[key] = schema.Var(v)
-			runtime.VarLock.Unlock()
+			setVar(runtime, key, string(v), q.Get("secret") == "true")
 
 		default:
 			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		}
 	}
 }
+
+// setVar stores a var, secret values are masked in the output of all following commands.
+func setVar(runtime *runtime.Runtime, key, value string, secret bool) {
+	if secret {
+		runtime.Masker.Add(value)
+	}
+
+	runtime.VarLock.Lock()
+	runtime.Vars[key] = schema.Var(value)
+	runtime.VarLock.Unlock()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -9,7 +10,9 @@ import (
 	"path"
 	"syscall"
 
+	"github.com/reeveci/reeve-lib/exe"
 	"github.com/reeveci/reeve-lib/logs"
+	"github.com/reeveci/reeve/reeve-common/tracing"
 	"github.com/reeveci/reeve/reeve-runner/api"
 	"github.com/reeveci/reeve/reeve-runner/runtime"
 )
@@ -34,8 +37,10 @@ func main() {
 		return
 	}
 
-	log := logs.NewDecorated(os.Stdout, "", logs.NewDefaultDecorator("", "%-18s >"))
-	errorLog := logs.NewDecorated(os.Stderr, "", logs.NewDefaultDecorator(":!", "%-18s >"))
+	// log output and control messages share the output, so that control messages always start on a new line
+	output := runtime.NewOutput()
+	log := logs.NewDecorated(output.Writer(os.Stdout), "", logs.NewDefaultDecorator("", "%-18s >"))
+	errorLog := logs.NewDecorated(output.Writer(os.Stderr), "", logs.NewDefaultDecorator(":!", "%-18s >"))
 
 	runtime, err := runtime.GetRuntime()
 	if err != nil {
@@ -46,6 +51,7 @@ func main() {
 
 	runtime.Log = log
 	runtime.ErrorLog = errorLog
+	runtime.Control = output.Control(os.Stdout)
 
 	// Parse pipeline
 	decoder := json.NewDecoder(os.Stdin)
@@ -57,6 +63,16 @@ func main() {
 		return
 	}
 
+	// Approval decisions follow the pipeline on stdin
+	runtime.ReadDecisions(decoder)
+
+	shutdownTracing, err := tracing.Setup("reeve-runner", exe.GetEnvDef("REEVE_TRACING_EXPORTER", tracing.EXPORTER_NONE), exe.GetEnvDef("REEVE_TRACING_ENDPOINT", ""), exe.GetEnvDef("REEVE_TRACING_FILE", ""), buildVersion)
+	if err != nil {
+		errorLog.Subsystem("init").Printf("error setting up tracing - %s\n", err)
+		os.Exit(1)
+		return
+	}
+
 	// Check for runtime signals
 	signals := make(chan os.Signal, 2)
 	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
@@ -70,9 +86,14 @@ func main() {
 	go api.ServeAPI(runtime)
 
 	// Run pipeline
-	success := runtime.Run()
+	success := runtime.Run(tracing.FromEnv())
+
+	err = shutdownTracing(context.Background())
+	if err != nil {
+		errorLog.Subsystem("tracing").Printf("error flushing traces - %s\n", err)
+	}
 
-	if !success {
-		os.Exit(2)
+	if exitCode := runtime.ExitCode(success); exitCode != 0 {
+		os.Exit(exitCode)
 	}
 }
@@ -0,0 +1,144 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/reeveci/reeve-lib/logs"
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// TASK_APPROVAL is the reserved task of approval steps, which pause the pipeline until the step has been approved or rejected on the server.
+// The optional params message and approvers (space separated approval token names) are passed to the server.
+const TASK_APPROVAL = "@approval"
+
+// CONTROL_PREFIX marks lines written to stdout which are handled by the worker instead of being logged.
+const CONTROL_PREFIX = "::reeve::"
+
+const CONTROL_APPROVAL = "approval"
+const CONTROL_ARTIFACT = "artifact"
+
+type ControlMessage struct {
+	Type     string           `json:"type"`
+	Approval *ApprovalRequest `json:"approval,omitempty"`
+	Artifact *ArtifactChunk   `json:"artifact,omitempty"`
+}
+
+type ApprovalRequest struct {
+	Step      int      `json:"step"`
+	Name      string   `json:"name"`
+	Message   string   `json:"message,omitempty"`
+	Approvers []string `json:"approvers,omitempty"`
+}
+
+// ApprovalDecision is sent by the worker on stdin after the pipeline once an approval step has been decided.
+type ApprovalDecision struct {
+	Approved bool   `json:"approved"`
+	By       string `json:"by"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// ReadDecisions reads approval decisions from decoder until the input is closed.
+func (runtime *Runtime) ReadDecisions(decoder *json.Decoder) {
+	decisions := make(chan ApprovalDecision)
+	runtime.decisions = decisions
+
+	go func() {
+		defer close(decisions)
+
+		for {
+			var decision ApprovalDecision
+			err := decoder.Decode(&decision)
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					runtime.ErrorLog.Subsystem("approval").Printf("error parsing approval decision from stdin - %s\n", err)
+				}
+				return
+			}
+
+			decisions <- decision
+		}
+	}()
+}
+
+// RunApproval requests an approval for a step from the worker and waits for the decision.
+func (runtime *Runtime) RunApproval(ctx context.Context, stepNumber int, step schema.Step, log, errorLog logs.LogWriter) bool {
+	runtime.approvalLock.Lock()
+	defer runtime.approvalLock.Unlock()
+
+	runtime.VarLock.Lock()
+	resolvedConfig, missingEnv, _, err := step.RunConfig.Resolve(runtime.Pipeline.Env, runtime.Vars)
+	runtime.VarLock.Unlock()
+
+	if err != nil {
+		errorLog.Printf("failed to request approval - resolving params failed - %s\n", err)
+		return false
+	}
+	if len(missingEnv) > 0 {
+		errorLog.Printf("failed to request approval - missing environment variables %s\n", strings.Join(missingEnv, ", "))
+		return false
+	}
+
+	request := ApprovalRequest{
+		Step:      stepNumber,
+		Name:      step.Name,
+		Message:   runtime.censor(resolvedConfig.Params["message"]),
+		Approvers: strings.Fields(resolvedConfig.Params["approvers"]),
+	}
+
+	err = runtime.writeControl(ControlMessage{Type: CONTROL_APPROVAL, Approval: &request})
+	if err != nil {
+		errorLog.Printf("failed to request approval - %s\n", err)
+		return false
+	}
+
+	if len(request.Approvers) > 0 {
+		log.Printf("waiting for approval by %s\n", strings.Join(request.Approvers, ", "))
+	} else {
+		log.Println("waiting for approval")
+	}
+
+	select {
+	case <-ctx.Done():
+		errorLog.Println("approval canceled")
+		return false
+
+	case decision, ok := <-runtime.decisions:
+		if !ok {
+			errorLog.Println("failed to receive approval decision - input closed")
+			return false
+		}
+
+		comment := ""
+		if decision.Comment != "" {
+			comment = fmt.Sprintf(" - %s", runtime.censor(decision.Comment))
+		}
+
+		if decision.Approved {
+			log.Printf("approved by %s%s\n", decision.By, comment)
+			return true
+		}
+
+		errorLog.Printf("rejected by %s%s\n", decision.By, comment)
+		return false
+	}
+}
+
+func (runtime *Runtime) writeControl(message ControlMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("error encoding control message - %s", err)
+	}
+
+	// messages are written at once, so that they are not interleaved with log output
+	_, err = runtime.Control.Write([]byte(CONTROL_PREFIX + string(data) + "\n"))
+	return err
+}
+
+func (runtime *Runtime) censor(value string) string {
+	return runtime.Masker.Mask(value)
+}
@@ -0,0 +1,87 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// ARTIFACT_CHUNK_SIZE is the number of bytes sent to the worker in a single control message.
+const ARTIFACT_CHUNK_SIZE = 2 * 1024
+
+var artifactNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// ValidArtifactName returns true if name may be used as artifact name.
+func ValidArtifactName(name string) bool {
+	return len(name) <= 128 && artifactNameRegex.MatchString(name)
+}
+
+// ArtifactChunk is a part of an artifact sent to the worker.
+// The runner runs in a separate container, so artifacts are passed to the worker as control messages, which upload them to the server.
+// Chunks with the same ID belong to the same artifact, the last chunk is marked as done or aborted.
+// Chunks are numbered consecutively starting at 0, so that the worker can detect lost chunks.
+type ArtifactChunk struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Seq     int    `json:"seq"`
+	Data    []byte `json:"data,omitempty"`
+	Done    bool   `json:"done,omitempty"`
+	Aborted bool   `json:"aborted,omitempty"`
+}
+
+// PublishArtifact passes an artifact to the worker, which uploads it to the server once it is complete.
+func (runtime *Runtime) PublishArtifact(name string, content io.Reader) (size int64, err error) {
+	if !ValidArtifactName(name) {
+		return 0, fmt.Errorf("invalid artifact name %s", name)
+	}
+
+	id := uuid.NewString()
+	seq := 0
+	send := func(chunk ArtifactChunk) error {
+		chunk.ID, chunk.Name, chunk.Seq = id, name, seq
+		seq++
+		return runtime.writeControl(ControlMessage{Type: CONTROL_ARTIFACT, Artifact: &chunk})
+	}
+
+	buffer := make([]byte, ARTIFACT_CHUNK_SIZE)
+
+	for {
+		n, readErr := readChunk(content, buffer)
+		if n > 0 {
+			size += int64(n)
+			err = send(ArtifactChunk{Data: buffer[:n]})
+			if err != nil {
+				return size, fmt.Errorf("error sending artifact - %s", err)
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			send(ArtifactChunk{Aborted: true})
+			return size, fmt.Errorf("error reading artifact - %s", readErr)
+		}
+	}
+
+	err = send(ArtifactChunk{Done: true})
+	if err != nil {
+		return size, fmt.Errorf("error sending artifact - %s", err)
+	}
+
+	runtime.Log.Subsystem("artifact").Printf("published artifact %s (%v bytes)\n", name, size)
+	return size, nil
+}
+
+// readChunk fills buffer unless the input ends, io.EOF is returned at the end of the input only.
+// In contrast to io.ReadFull, an incomplete upload can be distinguished from the end of the input.
+func readChunk(r io.Reader, buffer []byte) (n int, err error) {
+	for n < len(buffer) && err == nil {
+		var read int
+		read, err = r.Read(buffer[n:])
+		n += read
+	}
+	return
+}
@@ -0,0 +1,227 @@
+package runtime
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/reeveci/reeve-lib/logs"
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// TASK_CACHE is the reserved task of cache steps, which restore a cache into the workspace and save it once the pipeline succeeded.
+// The param key is a template supporting hashFiles with paths relative to /reeve, paths are directories or files inside /reeve (split by spaces).
+const TASK_CACHE = "@cache"
+
+const WORKSPACE_PATH = "/reeve"
+
+// Caches are stored as tarballs in a worker-local Docker volume, the least recently used ones are evicted when the volume exceeds the maximum size.
+// All file operations run in a helper container, since the workspace is a Docker volume as well.
+
+const hashFilesScript = `found=
+for p in "$@"; do for f in $p; do [ -f "$f" ] && found=1; done; done
+[ -z "$found" ] && exit 0
+for p in "$@"; do for f in $p; do if [ -f "$f" ]; then echo "$f"; cat "$f"; fi; done; done | sha256sum | cut -d' ' -f1`
+
+const restoreCacheScript = `f="/cache/$1.tar"
+if [ -f "$f" ]; then
+  touch "$f" && tar xf "$f" -C / && echo hit
+else
+  echo miss
+fi`
+
+const saveCacheScript = `set -e
+key=$1; max=$2; shift 2
+list=
+for p in "$@"; do [ -e "/$p" ] && list="$list $p"; done
+if [ -z "$list" ]; then echo empty; exit 0; fi
+tmp="/cache/.$key.$$.tmp"
+tar cf "$tmp" -C / $list
+mv "$tmp" "/cache/$key.tar"
+if [ "$max" -gt 0 ]; then
+  total=$(du -sk /cache | cut -f1)
+  for f in $(ls -tr /cache/*.tar); do
+    [ "$total" -le "$max" ] && break
+    [ "$f" = "/cache/$key.tar" ] && continue
+    size=$(du -k "$f" | cut -f1)
+    rm -f "$f"
+    total=$((total - size))
+  done
+fi
+echo saved`
+
+type cacheEntry struct {
+	key   string
+	file  string
+	paths []string
+}
+
+// RunCache restores a cache and registers it to be saved after the pipeline succeeded.
+// A cache miss does not fail the step.
+func (runtime *Runtime) RunCache(step schema.Step, log, errorLog logs.LogWriter) bool {
+	runtime.VarLock.Lock()
+	resolvedConfig, missingEnv, _, err := step.RunConfig.Resolve(runtime.Pipeline.Env, runtime.Vars)
+	runtime.VarLock.Unlock()
+
+	if err != nil {
+		errorLog.Printf("failed to restore cache - resolving params failed - %s\n", err)
+		return false
+	}
+	if len(missingEnv) > 0 {
+		errorLog.Printf("failed to restore cache - missing environment variables %s\n", strings.Join(missingEnv, ", "))
+		return false
+	}
+
+	paths, err := cachePaths(resolvedConfig.Params["paths"])
+	if err != nil {
+		errorLog.Printf("failed to restore cache - %s\n", err)
+		return false
+	}
+
+	key, err := runtime.cacheKey(resolvedConfig.Params["key"])
+	if err != nil {
+		errorLog.Printf("failed to restore cache - %s\n", err)
+		return false
+	}
+
+	entry := cacheEntry{key: key, file: runtime.cacheFile(key), paths: paths}
+
+	output, err := runtime.cacheHelper([]string{"-v", fmt.Sprintf("%s:/cache", runtime.CacheVolume)}, restoreCacheScript, entry.file)
+	if err != nil {
+		errorLog.Printf("failed to restore cache %s - %s\n", key, err)
+		return false
+	}
+
+	if output == "hit" {
+		log.Printf("restored cache %s\n", key)
+		return true
+	}
+
+	log.Printf("cache %s not found, it is saved once the pipeline succeeded\n", key)
+
+	runtime.cacheLock.Lock()
+	runtime.caches = append(runtime.caches, entry)
+	runtime.cacheLock.Unlock()
+
+	return true
+}
+
+// SaveCaches saves all caches which have not been restored, failures are logged only.
+func (runtime *Runtime) SaveCaches() {
+	log := runtime.Log.Subsystem("cache")
+	errorLog := runtime.ErrorLog.Subsystem("cache")
+
+	runtime.cacheLock.Lock()
+	caches := runtime.caches
+	runtime.caches = nil
+	runtime.cacheLock.Unlock()
+
+	for _, entry := range caches {
+		args := []string{entry.file, strconv.Itoa(runtime.CacheMaxSize * 1024)}
+		for _, p := range entry.paths {
+			args = append(args, strings.TrimPrefix(p, "/"))
+		}
+
+		output, err := runtime.cacheHelper([]string{"-v", fmt.Sprintf("%s:/cache", runtime.CacheVolume)}, saveCacheScript, args...)
+		if err != nil {
+			errorLog.Printf("failed to save cache %s - %s\n", entry.key, err)
+			continue
+		}
+
+		if output == "empty" {
+			log.Printf("not saving cache %s - paths do not exist\n", entry.key)
+		} else {
+			log.Printf("saved cache %s\n", entry.key)
+		}
+	}
+}
+
+// cacheFile returns the file name of a cache, which is namespaced by the pipeline name and its trust level.
+// Pipelines running untrusted tasks may modify the workspace, so their caches are never restored by trusted pipelines.
+func (runtime *Runtime) cacheFile(key string) string {
+	namespace := "untrusted"
+	if runtime.pipelineTrusted() {
+		namespace = "trusted"
+	}
+
+	sum := sha256.Sum256([]byte(runtime.Pipeline.Name + "\x00" + key))
+	return namespace + "-" + hex.EncodeToString(sum[:16])
+}
+
+// cacheKey evaluates the key template.
+func (runtime *Runtime) cacheKey(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", fmt.Errorf("missing param key")
+	}
+
+	tmpl, err := template.New("key").Option("missingkey=error").Funcs(template.FuncMap{
+		"hashFiles": func(patterns ...string) (string, error) {
+			return runtime.cacheHelper(nil, hashFilesScript, patterns...)
+		},
+	}).Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid key - %s", err)
+	}
+
+	var key bytes.Buffer
+	err = tmpl.Execute(&key, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid key - %s", err)
+	}
+
+	return strings.TrimSpace(key.String()), nil
+}
+
+// cachePaths validates the cache paths, which must be inside the workspace.
+func cachePaths(value string) ([]string, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("missing param paths")
+	}
+
+	paths := make([]string, len(fields))
+	for i, field := range fields {
+		p := path.Clean(field)
+		if !path.IsAbs(p) {
+			p = path.Join(WORKSPACE_PATH, p)
+		}
+		if !strings.HasPrefix(p, WORKSPACE_PATH+"/") {
+			return nil, fmt.Errorf("invalid path %s - caches must be inside %s", field, WORKSPACE_PATH)
+		}
+		paths[i] = p
+	}
+	return paths, nil
+}
+
+// cacheHelper runs a script in a helper container with the workspace mounted and returns its trimmed output.
+func (runtime *Runtime) cacheHelper(volumes []string, script string, args ...string) (string, error) {
+	executor, err := runtime.containerExecutor()
+	if err != nil {
+		return "", err
+	}
+
+	dockerArgs := []string{"run", "--rm", "-v", fmt.Sprintf("%s:%s", executor.workspaceVolume, WORKSPACE_PATH), "-w", WORKSPACE_PATH}
+	dockerArgs = append(dockerArgs, executor.labelArgs()...)
+	dockerArgs = append(dockerArgs, volumes...)
+	dockerArgs = append(dockerArgs, runtime.CacheImage, "sh", "-c", script, "sh")
+	dockerArgs = append(dockerArgs, args...)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(executor.Command, dockerArgs...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%s - %s", err, runtime.censor(message))
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
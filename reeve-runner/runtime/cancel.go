@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Exit codes of the runner, 1 is used if the runner could not be initialized.
+const (
+	EXIT_CODE_FAILED   = 2
+	EXIT_CODE_CANCELED = 3
+)
+
+// ErrCanceled is the cause of the pipeline context once the pipeline has been canceled.
+var ErrCanceled = errors.New("pipeline canceled")
+
+// STATUS_CANCELED is the status fact of steps run after the pipeline has been canceled.
+// Only steps including it in their status condition are run, e.g. to clean up external resources.
+const STATUS_CANCELED = "canceled"
+
+// Cancel cancels the pipeline, stopping running tasks and skipping all remaining steps which do not run on cancellation.
+func (runtime *Runtime) Cancel() {
+	runtime.cancelLock.Lock()
+	defer runtime.cancelLock.Unlock()
+
+	if !runtime.canceled {
+		runtime.canceled = true
+		close(runtime.done)
+	}
+}
+
+// Canceled returns true if the pipeline has been canceled.
+func (runtime *Runtime) Canceled() bool {
+	return !runtime.CheckHealth()
+}
+
+// ExitCode returns the exit code of the runner after the pipeline has finished.
+func (runtime *Runtime) ExitCode(success bool) int {
+	switch {
+	case runtime.Canceled():
+		return EXIT_CODE_CANCELED
+	case !success:
+		return EXIT_CODE_FAILED
+	default:
+		return 0
+	}
+}
+
+// withCancel returns a context which is canceled with ErrCanceled once the pipeline has been canceled.
+func (runtime *Runtime) withCancel(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	go func() {
+		select {
+		case <-runtime.done:
+			cancel(ErrCanceled)
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() { cancel(nil) }
+}
+
+// isCanceled returns true if ctx has been canceled because the pipeline has been canceled.
+func isCanceled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrCanceled)
+}
+
+// detach returns a context which is not canceled together with ctx, but keeps its deadline.
+// It is used to run steps after the pipeline has been canceled.
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detached, deadline)
+	}
+	return context.WithCancel(detached)
+}
+
+// stopOnCancel calls stop if the pipeline is canceled while a task is running.
+// release must be called once the task has exited, it returns true if the task has been stopped.
+func stopOnCancel(ctx context.Context, stop func()) (release func() bool) {
+	exited := make(chan struct{})
+	var canceled atomic.Bool
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			if isCanceled(ctx) {
+				canceled.Store(true)
+				stop()
+			}
+		case <-exited:
+		}
+	}()
+
+	var once sync.Once
+	return func() bool {
+		once.Do(func() { close(exited) })
+		return canceled.Load()
+	}
+}
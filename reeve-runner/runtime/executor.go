@@ -0,0 +1,115 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/reeveci/reeve-lib/exe"
+	"github.com/reeveci/reeve-lib/logs"
+)
+
+// Runtime environments selecting the executor, see NewExecutor.
+const (
+	// RUNTIME_ENV_HOST runs tasks using the Docker CLI, with the runner running on the host.
+	RUNTIME_ENV_HOST = "host"
+	// RUNTIME_ENV_DOCKER runs tasks using the Docker CLI, with the runner running in a container which is attached to the pipeline network.
+	RUNTIME_ENV_DOCKER = "docker"
+	// RUNTIME_ENV_PODMAN runs tasks using the Podman CLI, with the runner running on the host.
+	RUNTIME_ENV_PODMAN = "podman"
+	// RUNTIME_ENV_LOCAL runs trusted tasks as local processes.
+	RUNTIME_ENV_LOCAL = "local"
+)
+
+// PARAM_RUNTIME_ENV is the reserved setup param selecting the runtime environment of the pipeline.
+// Only the runtime environments allowed by the worker may be selected, see Runtime.AllowedRuntimeEnvs.
+const PARAM_RUNTIME_ENV = "REEVE_RUNTIME_ENV"
+
+// LABEL_ACTIVITY is the label containing the activity ID of the pipeline, which is set on all containers, networks and volumes created by the runner.
+// It is used by the janitor of the worker to remove resources left behind by runners which have been killed.
+const LABEL_ACTIVITY = "reeve.activity"
+
+// LABEL_WORKER is the label containing the ID of the worker which started the runner, which is set together with LABEL_ACTIVITY.
+// The janitor of the worker only removes resources labeled with its own ID.
+const LABEL_WORKER = "reeve.worker"
+
+// Executor runs the tasks of a pipeline.
+type Executor interface {
+	// Prepare creates the resources shared by all tasks of the pipeline, such as the workspace.
+	Prepare(errorLog logs.LogWriter) bool
+	// RunTask runs a task until it exits, its timeout expires or the pipeline is canceled, see stopOnCancel.
+	RunTask(ctx context.Context, task Task, log, errorLog logs.LogWriter) TaskResult
+	// Cleanup removes the resources created by Prepare.
+	Cleanup(errorLog logs.LogWriter)
+}
+
+// Task is a resolved task, which is run by an executor.
+type Task struct {
+	// Image is the container image of the task, it is not used by the local executor.
+	Image   string
+	Command []string
+	// Env contains the params and runner variables passed to the task as KEY=value.
+	Env   []string
+	Input io.Reader
+	// Directory is a host directory the task is run in.
+	Directory string
+	User      string
+	Trusted   bool
+	// Timeout limits the duration of the task, 0 means unlimited.
+	Timeout time.Duration
+	Options TaskOptions
+}
+
+// NewExecutor returns the executor for the runtime environment of the runtime.
+func NewExecutor(runtime *Runtime) (Executor, error) {
+	switch runtime.RuntimeEnv {
+	case RUNTIME_ENV_HOST:
+		return NewCLIExecutor(runtime, runtime.DockerCommand, "/var/run/docker.sock", false), nil
+	case RUNTIME_ENV_DOCKER:
+		return NewCLIExecutor(runtime, runtime.DockerCommand, "/var/run/docker.sock", true), nil
+	case RUNTIME_ENV_PODMAN:
+		return NewCLIExecutor(runtime, exe.GetEnvDef("REEVE_PODMAN_COMMAND", "podman"), exe.GetEnvDef("REEVE_PODMAN_SOCKET", "/run/podman/podman.sock"), false), nil
+	case RUNTIME_ENV_LOCAL:
+		return NewLocalExecutor(runtime), nil
+	default:
+		return nil, fmt.Errorf("invalid REEVE_RUNTIME_ENV %s - must be one of %s, %s, %s or %s", runtime.RuntimeEnv, RUNTIME_ENV_HOST, RUNTIME_ENV_DOCKER, RUNTIME_ENV_PODMAN, RUNTIME_ENV_LOCAL)
+	}
+}
+
+func validRuntimeEnv(runtimeEnv string) bool {
+	switch runtimeEnv {
+	case RUNTIME_ENV_HOST, RUNTIME_ENV_DOCKER, RUNTIME_ENV_PODMAN, RUNTIME_ENV_LOCAL:
+		return true
+	default:
+		return false
+	}
+}
+
+// selectExecutor replaces the executor if the pipeline selects another runtime environment using the setup param.
+func (runtime *Runtime) selectExecutor(setupParams map[string]string) error {
+	runtimeEnv := setupParams[PARAM_RUNTIME_ENV]
+	if runtimeEnv == "" || runtimeEnv == runtime.RuntimeEnv {
+		return nil
+	}
+	if !runtime.AllowedRuntimeEnvs[runtimeEnv] {
+		return fmt.Errorf("runtime environment %s is not allowed by the worker", runtimeEnv)
+	}
+
+	runtime.RuntimeEnv = runtimeEnv
+	executor, err := NewExecutor(runtime)
+	if err != nil {
+		return err
+	}
+	runtime.Executor = executor
+	return nil
+}
+
+// containerExecutor returns the executor if it runs tasks in containers, which is required for services and caches.
+func (runtime *Runtime) containerExecutor() (*CLIExecutor, error) {
+	executor, ok := runtime.Executor.(*CLIExecutor)
+	if !ok {
+		return nil, fmt.Errorf("not supported by the %s runtime environment", runtime.RuntimeEnv)
+	}
+	return executor, nil
+}
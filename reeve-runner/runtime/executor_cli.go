@@ -0,0 +1,234 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/reeveci/reeve-lib/logs"
+)
+
+// CLIExecutor runs tasks in containers using the Docker CLI or a compatible CLI such as Podman.
+// Tasks share a workspace volume and a private network.
+// Containers, networks and volumes are labeled with the activity ID, see LABEL_ACTIVITY.
+type CLIExecutor struct {
+	runtime *Runtime
+
+	// Command is the container CLI, e.g. docker or podman.
+	Command string
+	// Socket is the API socket of the container engine, which is mounted into trusted tasks.
+	Socket string
+	// Attach connects the runner container to the pipeline network, so that tasks can reach the runner API.
+	Attach bool
+
+	network         string
+	workspaceVolume string
+
+	networkCreated         bool
+	networkAttached        bool
+	workspaceVolumeCreated bool
+}
+
+func NewCLIExecutor(runtime *Runtime, command, socket string, attach bool) *CLIExecutor {
+	return &CLIExecutor{
+		runtime: runtime,
+
+		Command: command,
+		Socket:  socket,
+		Attach:  attach,
+
+		network:         "reeve-" + uuid.NewString(),
+		workspaceVolume: "reeve-" + uuid.NewString(),
+	}
+}
+
+func (executor *CLIExecutor) Prepare(errorLog logs.LogWriter) bool {
+	runtime := executor.runtime
+
+	success, err := runtime.RunCommand(executor.Command, append([]string{"network", "create"}, executor.labelArgs(executor.network)...), nil, nil)
+	if err != nil {
+		errorLog.Printf("failed to create network - %s\n", err)
+		return false
+	}
+	if !success {
+		errorLog.Printf("failed to create network\n")
+		return false
+	}
+	executor.networkCreated = true
+
+	if executor.Attach {
+		success, err := runtime.RunCommand(executor.Command, []string{"network", "connect", executor.network, runtime.hostname}, nil, nil)
+		if err != nil {
+			errorLog.Printf("failed to attach network - %s\n", err)
+			return false
+		}
+		if !success {
+			errorLog.Printf("failed to attach network\n")
+			return false
+		}
+		executor.networkAttached = true
+	}
+
+	success, err = runtime.RunCommand(executor.Command, append([]string{"volume", "create"}, executor.labelArgs(executor.workspaceVolume)...), nil, nil)
+	if err != nil {
+		errorLog.Printf("failed to create workspace volume - %s\n", err)
+		return false
+	}
+	if !success {
+		errorLog.Printf("failed to create workspace volume\n")
+		return false
+	}
+	executor.workspaceVolumeCreated = true
+
+	return true
+}
+
+func (executor *CLIExecutor) RunTask(ctx context.Context, task Task, log, errorLog logs.LogWriter) TaskResult {
+	runtime := executor.runtime
+
+	// the container is created before it is started, so that it can be stopped as soon as the task times out or the pipeline is canceled
+	name := "reeve-" + uuid.NewString()
+	args := append([]string{"create", "--rm", "-i"}, executor.containerArgs(task)...)
+	args = append(args, "--name", name, task.Image)
+	args = append(args, task.Command...)
+
+	// pulling the image and errors creating the container are part of the step output, like with docker run
+	success, err := runtime.RunCommand(executor.Command, args, nil, log.Subsystem(">"))
+	if err != nil {
+		errorLog.Printf("failed to create container - %s\n", err)
+		return TaskResult{ExitCode: -1}
+	}
+	if !success {
+		errorLog.Printf("failed to create container\n")
+		return TaskResult{ExitCode: -1}
+	}
+
+	var expired atomic.Bool
+	if task.Timeout > 0 {
+		timer := time.AfterFunc(task.Timeout, func() {
+			expired.Store(true)
+			errorLog.Println("task timed out - stopping container")
+			executor.stop(name, errorLog)
+		})
+		defer timer.Stop()
+	}
+
+	release := stopOnCancel(ctx, func() {
+		errorLog.Println("pipeline canceled - stopping container")
+		executor.stop(name, errorLog)
+	})
+
+	exitCode, err := runtime.RunCommandExitCode(executor.Command, []string{"start", "-a", "-i", name}, task.Input, log.Subsystem(">"))
+	if release() {
+		return TaskResult{Canceled: true, ExitCode: exitCode}
+	}
+	if expired.Load() {
+		return TaskResult{TimedOut: true, ExitCode: exitCode}
+	}
+	if err != nil {
+		errorLog.Printf("failed to run task - %s\n", err)
+		executor.remove(name)
+		return TaskResult{ExitCode: -1}
+	}
+
+	return TaskResult{Success: exitCode == 0, ExitCode: exitCode}
+}
+
+func (executor *CLIExecutor) Cleanup(errorLog logs.LogWriter) {
+	runtime := executor.runtime
+
+	if executor.workspaceVolumeCreated {
+		success, err := runtime.RunCommand(executor.Command, []string{"volume", "rm", "-f", executor.workspaceVolume}, nil, nil)
+		if err != nil {
+			errorLog.Printf("failed to remove workspace volume - %s\n", err)
+		} else if !success {
+			errorLog.Printf("failed to remove workspace volume\n")
+		}
+	}
+
+	if executor.networkAttached {
+		success, err := runtime.RunCommand(executor.Command, []string{"network", "disconnect", "-f", executor.network, runtime.hostname}, nil, nil)
+		if err != nil {
+			errorLog.Printf("failed to detach network - %s\n", err)
+		} else if !success {
+			errorLog.Printf("failed to detach network\n")
+		}
+	}
+
+	if executor.networkCreated {
+		success, err := runtime.RunCommand(executor.Command, []string{"network", "rm", executor.network}, nil, nil)
+		if err != nil {
+			errorLog.Printf("failed to remove network - %s\n", err)
+		} else if !success {
+			errorLog.Printf("failed to remove network\n")
+		}
+	}
+}
+
+// containerArgs returns the run options of a task container, without the container name and image.
+func (executor *CLIExecutor) containerArgs(task Task) []string {
+	args := []string{
+		"-v", fmt.Sprintf("%s:%s", executor.workspaceVolume, WORKSPACE_PATH),
+		"--network", executor.network,
+	}
+	args = append(args, executor.labelArgs()...)
+
+	for _, env := range task.Env {
+		args = append(args, "-e", env)
+	}
+
+	if task.Trusted && executor.Socket != "" {
+		args = append(args, "-v", fmt.Sprintf("%s:/var/run/docker.sock", executor.Socket))
+	}
+
+	args = append(args, task.Options.Args()...)
+
+	if len(task.Directory) > 0 {
+		args = append(args,
+			"-v", fmt.Sprintf("/%s:%s/mount:rw", strings.TrimPrefix(task.Directory, "/"), WORKSPACE_PATH),
+			"-w", WORKSPACE_PATH+"/mount",
+		)
+	}
+
+	if task.User != "" {
+		args = append(args, "-u", task.User)
+	}
+
+	return args
+}
+
+// labelArgs returns the label options marking a resource as owned by the activity of the pipeline and the worker, followed by args.
+// Resources are only labeled if the activity is known, so that the janitor of the worker can remove them once their runner is gone.
+func (executor *CLIExecutor) labelArgs(args ...string) []string {
+	if executor.runtime.ActivityID == "" {
+		return args
+	}
+	labels := []string{"--label", fmt.Sprintf("%s=%s", LABEL_ACTIVITY, executor.runtime.ActivityID)}
+	if executor.runtime.WorkerID != "" {
+		labels = append(labels, "--label", fmt.Sprintf("%s=%s", LABEL_WORKER, executor.runtime.WorkerID))
+	}
+	return append(labels, args...)
+}
+
+// stop stops a task container and kills it if it does not exit within the stop timeout.
+// Containers which have not been started yet are removed, so that they cannot be started anymore.
+func (executor *CLIExecutor) stop(name string, errorLog logs.LogWriter) {
+	runtime := executor.runtime
+
+	success, err := runtime.RunCommand(executor.Command, []string{"stop", "--time", fmt.Sprint(int(runtime.StopTimeout.Seconds())), name}, nil, nil)
+	stopped := err == nil && success
+
+	// stopping a created container succeeds without removing it, and the container is gone already if it was stopped after being started
+	if !executor.remove(name) && !stopped {
+		errorLog.Printf("failed to kill container\n")
+	}
+}
+
+// remove kills and removes a task container, it returns false if the container could not be removed.
+func (executor *CLIExecutor) remove(name string) bool {
+	success, err := executor.runtime.RunCommand(executor.Command, []string{"rm", "-f", name}, nil, nil)
+	return err == nil && success
+}
@@ -0,0 +1,172 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/reeveci/reeve-lib/logs"
+)
+
+// LocalExecutor runs trusted tasks as local processes in a temporary workspace directory.
+// The task image is only used to determine whether a task is trusted, tasks without command run their input using sh.
+type LocalExecutor struct {
+	runtime *Runtime
+
+	workspace string
+}
+
+func NewLocalExecutor(runtime *Runtime) *LocalExecutor {
+	return &LocalExecutor{runtime: runtime}
+}
+
+func (executor *LocalExecutor) Prepare(errorLog logs.LogWriter) bool {
+	workspace, err := os.MkdirTemp("", "reeve-workspace-")
+	if err != nil {
+		errorLog.Printf("failed to create workspace directory - %s\n", err)
+		return false
+	}
+	executor.workspace = workspace
+
+	return true
+}
+
+func (executor *LocalExecutor) RunTask(ctx context.Context, task Task, log, errorLog logs.LogWriter) TaskResult {
+	runtime := executor.runtime
+
+	if !task.Trusted {
+		errorLog.Printf("failed to run task - only trusted tasks can be run by the %s runtime environment\n", RUNTIME_ENV_LOCAL)
+		return TaskResult{ExitCode: -1}
+	}
+	if task.User != "" {
+		errorLog.Printf("failed to run task - user is not supported by the %s runtime environment\n", RUNTIME_ENV_LOCAL)
+		return TaskResult{ExitCode: -1}
+	}
+
+	command := task.Command
+	if len(command) == 0 {
+		command = []string{"sh"}
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = executor.workspace
+	if task.Directory != "" {
+		cmd.Dir = task.Directory
+	}
+	cmd.Env = append([]string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + os.Getenv("HOME"),
+		"REEVE_WORKSPACE=" + executor.workspace,
+	}, task.Env...)
+	cmd.Stdin = task.Input
+	// run the task in its own process group, so that child processes are stopped as well
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		errorLog.Printf("failed to run task - %s\n", err)
+		return TaskResult{ExitCode: -1}
+	}
+	cmd.Stderr = cmd.Stdout
+
+	err = cmd.Start()
+	if err != nil {
+		errorLog.Printf("failed to run task - %s\n", err)
+		return TaskResult{ExitCode: -1}
+	}
+
+	group := &processGroup{pid: cmd.Process.Pid}
+
+	var expired atomic.Bool
+	if task.Timeout > 0 {
+		timer := time.AfterFunc(task.Timeout, func() {
+			expired.Store(true)
+			errorLog.Println("task timed out - stopping process")
+			group.stop(runtime.StopTimeout)
+		})
+		defer timer.Stop()
+	}
+
+	release := stopOnCancel(ctx, func() {
+		errorLog.Println("pipeline canceled - stopping process")
+		group.stop(runtime.StopTimeout)
+	})
+
+	err = FilterSensitive(output, log.Subsystem(">"), runtime.Masker)
+	if err != nil {
+		panic(err)
+	}
+
+	err = cmd.Wait()
+	group.release()
+	exitCode := cmd.ProcessState.ExitCode()
+	if release() {
+		return TaskResult{Canceled: true, ExitCode: exitCode}
+	}
+	if expired.Load() {
+		return TaskResult{TimedOut: true, ExitCode: exitCode}
+	}
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			errorLog.Printf("failed to run task - %s\n", err)
+			return TaskResult{ExitCode: -1}
+		}
+	}
+
+	return TaskResult{Success: exitCode == 0, ExitCode: exitCode}
+}
+
+func (executor *LocalExecutor) Cleanup(errorLog logs.LogWriter) {
+	if executor.workspace == "" {
+		return
+	}
+
+	err := os.RemoveAll(executor.workspace)
+	if err != nil {
+		errorLog.Printf("failed to remove workspace directory - %s\n", err)
+	}
+}
+
+// processGroup signals the process group of a task until the task process has been reaped,
+// since the process group ID may be reused by unrelated processes afterwards.
+type processGroup struct {
+	lock     sync.Mutex
+	pid      int
+	released bool
+	kills    []*time.Timer
+}
+
+// stop terminates the process group and kills it if it does not exit within timeout.
+func (group *processGroup) stop(timeout time.Duration) {
+	group.lock.Lock()
+	defer group.lock.Unlock()
+
+	if group.released {
+		return
+	}
+
+	syscall.Kill(-group.pid, syscall.SIGTERM)
+	group.kills = append(group.kills, time.AfterFunc(timeout, func() {
+		group.lock.Lock()
+		defer group.lock.Unlock()
+
+		if !group.released {
+			syscall.Kill(-group.pid, syscall.SIGKILL)
+		}
+	}))
+}
+
+// release must be called once the task process has been reaped, pending kills are canceled.
+func (group *processGroup) release() {
+	group.lock.Lock()
+	defer group.lock.Unlock()
+
+	group.released = true
+	for _, kill := range group.kills {
+		kill.Stop()
+	}
+}
@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/reeveci/reeve-lib/filter"
+	"github.com/reeveci/reeve/reeve-common/mask"
+)
+
+// Masker replaces secrets in log lines, including multi-line secrets and common encodings.
+// Secrets may be added while output is being filtered, e.g. secret vars set via the runner API.
+type Masker struct {
+	lock     sync.RWMutex
+	secrets  map[string]bool
+	patterns map[string]bool
+	replacer *strings.Replacer
+	// lines is the maximum number of lines of a pattern
+	lines int
+}
+
+func NewMasker() *Masker {
+	return &Masker{
+		secrets:  make(map[string]bool),
+		patterns: make(map[string]bool),
+		replacer: strings.NewReplacer(),
+		lines:    1,
+	}
+}
+
+// Add registers a secret and its variants.
+func (m *Masker) Add(secret string) {
+	if secret == "" {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.secrets[secret] {
+		return
+	}
+	m.secrets[secret] = true
+
+	for _, pattern := range mask.Patterns(secret) {
+		m.patterns[pattern] = true
+		m.lines = max(m.lines, strings.Count(pattern, "\n")+1)
+	}
+	m.replacer = mask.NewReplacer(m.patterns)
+}
+
+// Mask replaces all known secrets in value.
+func (m *Masker) Mask(value string) string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.replacer.Replace(value)
+}
+
+// Lines returns the maximum number of lines a secret may span.
+func (m *Masker) Lines() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.lines
+}
+
+// FilterSensitive copies the lines of r to w, masking all secrets known to masker.
+// Lines are held back until all multi-line secrets starting in them are complete, so that they are masked across lines.
+func FilterSensitive(r io.Reader, w io.Writer, masker *Masker) error {
+	var pending string
+	var lines int
+
+	err := filter.LineFilter(r, w, func(line string) string {
+		pending += line
+		lines++
+		if lines < masker.Lines() {
+			return ""
+		}
+
+		// the first lines are written once no masked secret crosses their end
+		masked := masker.Mask(pending)
+		end := 0
+		for {
+			end += strings.IndexByte(pending[end:], '\n') + 1
+			prefix := masker.Mask(pending[:end])
+			if end == len(pending) || strings.HasPrefix(masked, prefix) {
+				pending = pending[end:]
+				lines = strings.Count(pending, "\n")
+				return prefix
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if pending != "" {
+		_, err = w.Write([]byte(masker.Mask(pending)))
+	}
+	return err
+}
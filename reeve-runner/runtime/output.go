@@ -0,0 +1,58 @@
+package runtime
+
+import (
+	"io"
+	"sync"
+)
+
+// Output serializes the log output of the runner and control messages.
+// The worker reads stdout and stderr of the runner from a single pipe, so a control message written while a log line
+// is incomplete would be spliced into that line. Control messages therefore terminate an incomplete line first.
+type Output struct {
+	lock     sync.Mutex
+	lineOpen bool
+}
+
+func NewOutput() *Output {
+	return &Output{}
+}
+
+// Writer returns a writer for log output to target.
+func (o *Output) Writer(target io.Writer) io.Writer {
+	return &outputWriter{output: o, target: target}
+}
+
+// Control returns a writer for control messages to target, each write must consist of complete lines.
+func (o *Output) Control(target io.Writer) io.Writer {
+	return &outputWriter{output: o, target: target, control: true}
+}
+
+type outputWriter struct {
+	output  *Output
+	target  io.Writer
+	control bool
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	w.output.lock.Lock()
+	defer w.output.lock.Unlock()
+
+	if w.control && w.output.lineOpen {
+		// the rest of the interrupted line continues on the next line
+		_, err := w.target.Write([]byte{'\n'})
+		if err != nil {
+			return 0, err
+		}
+		w.output.lineOpen = false
+	}
+
+	n, err := w.target.Write(p)
+	if n > 0 {
+		w.output.lineOpen = p[n-1] != '\n'
+	}
+	return n, err
+}
@@ -0,0 +1,122 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reeveci/reeve-lib/logs"
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// PARAM_RETRY is the reserved step param setting the maximum number of attempts of a task.
+const PARAM_RETRY = "REEVE_RETRY"
+
+// PARAM_RETRY_BACKOFF is the reserved step param setting the delay before the first retry, which is doubled after each attempt.
+const PARAM_RETRY_BACKOFF = "REEVE_RETRY_BACKOFF"
+
+// PARAM_RETRY_EXIT_CODES is the reserved step param limiting retries to the listed exit codes (split by spaces).
+const PARAM_RETRY_EXIT_CODES = "REEVE_RETRY_EXIT_CODES"
+
+const DEFAULT_RETRY_BACKOFF = 10 * time.Second
+const MAX_RETRY_BACKOFF = 5 * time.Minute
+
+type retryPolicy struct {
+	attempts  int
+	backoff   time.Duration
+	exitCodes map[int]bool
+}
+
+// RunTaskWithRetry runs a task until it succeeds or the retry policy of the step is exhausted.
+// The result is the one of the last attempt, including the number of attempts.
+func (runtime *Runtime) RunTaskWithRetry(ctx context.Context, config schema.RunConfig, log, errorLog logs.LogWriter) (result TaskResult) {
+	policy, err := runtime.retryPolicy(config)
+	if err != nil {
+		errorLog.Printf("failed to run task - %s\n", err)
+		return TaskResult{ExitCode: -1, Attempts: 1}
+	}
+
+	retryLog := log.Subsystem("retry")
+	backoff := policy.backoff
+
+	for attempts := 1; ; attempts++ {
+		if attempts > 1 {
+			retryLog.Printf("running attempt %v/%v\n", attempts, policy.attempts)
+		}
+
+		result = runtime.RunTask(ctx, config, log, errorLog)
+		result.Attempts = attempts
+		if result.Success || attempts >= policy.attempts || !policy.retries(result) || ctx.Err() != nil {
+			return
+		}
+
+		if result.TimedOut {
+			retryLog.Printf("attempt %v/%v timed out - retrying in %s\n", attempts, policy.attempts, backoff)
+		} else {
+			retryLog.Printf("attempt %v/%v failed with exit code %v - retrying in %s\n", attempts, policy.attempts, result.ExitCode, backoff)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		backoff = min(backoff*2, MAX_RETRY_BACKOFF)
+	}
+}
+
+func (runtime *Runtime) retryPolicy(config schema.RunConfig) (policy retryPolicy, err error) {
+	runtime.VarLock.Lock()
+	resolvedConfig, _, _, err := config.Resolve(runtime.Pipeline.Env, runtime.Vars)
+	runtime.VarLock.Unlock()
+
+	if err != nil {
+		return policy, fmt.Errorf("resolving params failed - %s", err)
+	}
+
+	policy.attempts = 1
+	if value := resolvedConfig.Params[PARAM_RETRY]; value != "" {
+		policy.attempts, err = strconv.Atoi(value)
+		if err != nil || policy.attempts < 1 {
+			return policy, fmt.Errorf("invalid %s '%s' - must be a positive number of attempts", PARAM_RETRY, value)
+		}
+	}
+
+	policy.backoff = DEFAULT_RETRY_BACKOFF
+	if value := resolvedConfig.Params[PARAM_RETRY_BACKOFF]; value != "" {
+		policy.backoff, err = time.ParseDuration(value)
+		if err != nil || policy.backoff < 0 {
+			return policy, fmt.Errorf("invalid %s '%s' - must be a duration", PARAM_RETRY_BACKOFF, value)
+		}
+	}
+
+	if value := resolvedConfig.Params[PARAM_RETRY_EXIT_CODES]; value != "" {
+		policy.exitCodes = make(map[int]bool)
+		for _, field := range strings.Fields(value) {
+			code, err := strconv.Atoi(field)
+			if err != nil {
+				return policy, fmt.Errorf("invalid %s '%s' - must be a list of exit codes", PARAM_RETRY_EXIT_CODES, value)
+			}
+			policy.exitCodes[code] = true
+		}
+	}
+
+	return policy, nil
+}
+
+// retries returns true if a failed attempt may be retried.
+// Timed out attempts are only retried if no exit codes are listed, attempts which could not be run are never retried.
+func (policy retryPolicy) retries(result TaskResult) bool {
+	if result.TimedOut {
+		return policy.exitCodes == nil
+	}
+	if result.ExitCode < 0 {
+		return false
+	}
+	return policy.exitCodes == nil || policy.exitCodes[result.ExitCode]
+}
@@ -1,7 +1,9 @@
 package runtime
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,14 +11,23 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/charmbracelet/glamour"
-	"github.com/google/uuid"
-	"github.com/reeveci/reeve-lib/conditions"
 	"github.com/reeveci/reeve-lib/exe"
-	"github.com/reeveci/reeve-lib/filter"
 	"github.com/reeveci/reeve-lib/logs"
 	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-common/tracing"
+)
+
+// Phases of the pipeline execution reported by the health endpoints.
+const (
+	PHASE_STARTING = "starting"
+	PHASE_PREPARE  = "prepare"
+	PHASE_SETUP    = "setup"
+	PHASE_STEP     = "step"
+	PHASE_CLEANUP  = "cleanup"
+	PHASE_FINISHED = "finished"
 )
 
 var proxyEnv = []string{"HTTP_PROXY", "HTTPS_PROXY", "FTP_PROXY", "ALL_PROXY"}
@@ -32,28 +43,70 @@ type Runtime struct {
 
 	Log, ErrorLog logs.LogWriter
 
-	APIPort       string
+	// Control receives control messages for the worker, see CONTROL_PREFIX and Output.
+	Control   io.Writer
+	decisions <-chan ApprovalDecision
+	// approvalLock makes sure that only one approval is pending at a time, since decisions are received in order.
+	approvalLock sync.Mutex
+
+	APIPort string
+	// ActivityID is the activity of the pipeline set by the worker, it is used to label the resources created by the runner.
+	ActivityID string
+	// WorkerID is the ID of the worker set by the worker, it is used to label the resources created by the runner.
+	WorkerID string
+	// RuntimeEnv selects the executor, see NewExecutor.
 	RuntimeEnv    string
 	DockerCommand string
 	ForwardProxy  bool
 	NoDescription bool
 
-	hostname        string
-	network         string
-	workspaceVolume string
-	ApiUrl          string
+	// AllowedRuntimeEnvs may be selected by pipelines instead of RuntimeEnv, see PARAM_RUNTIME_ENV.
+	AllowedRuntimeEnvs map[string]bool
+
+	// Parallelism limits the number of steps running at once.
+	Parallelism int
+
+	// PipelineTimeout limits the duration of the whole pipeline, 0 means unlimited.
+	PipelineTimeout time.Duration
+	// StopTimeout is the time containers have to exit after being stopped, before they are killed.
+	StopTimeout time.Duration
+
+	// TaskLimits are the default resource limits of task containers.
+	TaskLimits ResourceLimits
+	// TrustedSecurity and UntrustedSecurity are applied to task containers depending on whether the task is trusted.
+	TrustedSecurity, UntrustedSecurity SecurityProfile
+
+	// CacheVolume is the worker-local Docker volume storing caches, CacheMaxSize limits its size in MB (0 means unlimited).
+	CacheVolume  string
+	CacheMaxSize int
+	// CacheImage is used for helper containers restoring and saving caches.
+	CacheImage string
+
+	// Executor runs the tasks of the pipeline.
+	Executor Executor
+
+	hostname string
+	ApiUrl   string
 
 	VarLock sync.Mutex
 	Vars    map[string]schema.Var
 
-	filters []string
+	// Masker replaces secret env and secret vars in the task output.
+	Masker *Masker
 
-	networkCreated         bool
-	networkAttached        bool
-	workspaceVolumeCreated bool
+	cacheLock sync.Mutex
+	caches    []cacheEntry
+
+	serviceLock sync.Mutex
+	services    []string
+	serviceLogs sync.WaitGroup
 
 	cancelLock sync.Mutex
 	canceled   bool
+	done       chan struct{}
+
+	phaseLock sync.Mutex
+	phase     string
 }
 
 func GetRuntime() (*Runtime, error) {
@@ -63,14 +116,56 @@ func GetRuntime() (*Runtime, error) {
 		DockerCommand: exe.GetEnvDef("REEVE_DOCKER_COMMAND", "docker"),
 		ForwardProxy:  exe.GetBoolEnvDef("REEVE_FORWARD_PROXY", true),
 		NoDescription: exe.GetBoolEnvDef("REEVE_NO_DESCRIPTION", false),
+		CacheVolume:   exe.GetEnvDef("REEVE_CACHE_VOLUME", "reeve-cache"),
+		CacheImage:    exe.GetEnvDef("REEVE_CACHE_IMAGE", "busybox"),
+		ActivityID:    exe.GetEnvDef("REEVE_ACTIVITY", ""),
+		WorkerID:      exe.GetEnvDef("REEVE_WORKER_ID", ""),
 
-		network:         "reeve-" + uuid.NewString(),
-		workspaceVolume: "reeve-" + uuid.NewString(),
+		Vars:   make(map[string]schema.Var),
+		Masker: NewMasker(),
 
-		Vars: make(map[string]schema.Var),
+		phase: PHASE_STARTING,
+
+		done: make(chan struct{}),
 	}
 
 	var err error
+	runtime.Parallelism, err = strconv.Atoi(exe.GetEnvDef("REEVE_PARALLELISM", "4"))
+	if err != nil || runtime.Parallelism < 1 {
+		return nil, fmt.Errorf("invalid REEVE_PARALLELISM - must be a positive number of steps")
+	}
+
+	runtime.PipelineTimeout, err = time.ParseDuration(exe.GetEnvDef("REEVE_PIPELINE_TIMEOUT", "0"))
+	if err != nil || runtime.PipelineTimeout < 0 {
+		return nil, fmt.Errorf("invalid REEVE_PIPELINE_TIMEOUT - must be a duration")
+	}
+	runtime.StopTimeout, err = time.ParseDuration(exe.GetEnvDef("REEVE_STOP_TIMEOUT", "10s"))
+	if err != nil || runtime.StopTimeout < 0 {
+		return nil, fmt.Errorf("invalid REEVE_STOP_TIMEOUT - must be a duration")
+	}
+
+	runtime.TaskLimits, err = ParseResourceLimits(
+		exe.GetEnvDef("REEVE_TASK_CPUS", ""),
+		exe.GetEnvDef("REEVE_TASK_MEMORY", ""),
+		exe.GetEnvDef("REEVE_TASK_PIDS_LIMIT", ""),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid task resource limits - %s", err)
+	}
+	runtime.TrustedSecurity, err = ParseSecurityProfile(exe.GetEnvDef("REEVE_TRUSTED_SECURITY", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REEVE_TRUSTED_SECURITY - %s", err)
+	}
+	runtime.UntrustedSecurity, err = ParseSecurityProfile(exe.GetEnvDef("REEVE_UNTRUSTED_SECURITY", "cap-drop no-new-privileges"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REEVE_UNTRUSTED_SECURITY - %s", err)
+	}
+
+	runtime.CacheMaxSize, err = strconv.Atoi(exe.GetEnvDef("REEVE_CACHE_MAX_SIZE", "5120"))
+	if err != nil || runtime.CacheMaxSize < 0 {
+		return nil, fmt.Errorf("invalid REEVE_CACHE_MAX_SIZE - must be a number of MB")
+	}
+
 	runtime.hostname, err = os.Hostname()
 	if err != nil {
 		return nil, fmt.Errorf("error resolving hostname - %s", err)
@@ -78,97 +173,80 @@ func GetRuntime() (*Runtime, error) {
 
 	runtime.ApiUrl = fmt.Sprintf("http://%s:%s", runtime.hostname, runtime.APIPort)
 
+	runtime.Executor, err = NewExecutor(&runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime.AllowedRuntimeEnvs = map[string]bool{runtime.RuntimeEnv: true}
+	for _, runtimeEnv := range strings.Fields(exe.GetEnvDef("REEVE_ALLOWED_RUNTIME_ENVS", "")) {
+		if !validRuntimeEnv(runtimeEnv) {
+			return nil, fmt.Errorf("invalid REEVE_ALLOWED_RUNTIME_ENVS - unknown runtime environment %s", runtimeEnv)
+		}
+		runtime.AllowedRuntimeEnvs[runtimeEnv] = true
+	}
+
 	return &runtime, nil
 }
 
 func (runtime *Runtime) Prepare() bool {
 	errorLog := runtime.ErrorLog.Subsystem("prepare")
 
-	runtime.filters = make([]string, 0, len(runtime.Pipeline.Env))
 	for _, env := range runtime.Pipeline.Env {
 		if env.Secret {
-			runtime.filters = append(runtime.filters, env.Value)
+			runtime.Masker.Add(env.Value)
 		}
 	}
 
-	success, err := runtime.RunCommand(runtime.DockerCommand, []string{"network", "create", runtime.network}, nil, nil)
-	if err != nil {
-		errorLog.Printf("failed to create network - %s\n", err)
-		return false
-	}
-	if !success {
-		errorLog.Printf("failed to create network\n")
-		return false
-	}
-	runtime.networkCreated = true
-
-	if runtime.RuntimeEnv == "docker" {
-		success, err := runtime.RunCommand(runtime.DockerCommand, []string{"network", "connect", runtime.network, runtime.hostname}, nil, nil)
-		if err != nil {
-			errorLog.Printf("failed to attach network - %s\n", err)
-			return false
-		}
-		if !success {
-			errorLog.Printf("failed to attach network\n")
-			return false
-		}
-		runtime.networkAttached = true
-	}
-
-	success, err = runtime.RunCommand(runtime.DockerCommand, []string{"volume", "create", runtime.workspaceVolume}, nil, nil)
-	if err != nil {
-		errorLog.Printf("failed to create workspace volume - %s\n", err)
-		return false
-	}
-	if !success {
-		errorLog.Printf("failed to create workspace volume\n")
-		return false
-	}
-	runtime.workspaceVolumeCreated = true
-
-	return true
+	return runtime.Executor.Prepare(errorLog)
 }
 
 func (runtime *Runtime) Cleanup() {
+	runtime.SetPhase(PHASE_CLEANUP)
+
 	errorLog := runtime.ErrorLog.Subsystem("cleanup")
 
-	if runtime.workspaceVolumeCreated {
-		success, err := runtime.RunCommand(runtime.DockerCommand, []string{"volume", "rm", "-f", runtime.workspaceVolume}, nil, nil)
-		if err != nil {
-			errorLog.Printf("failed to remove workspace volume - %s\n", err)
-		} else if !success {
-			errorLog.Printf("failed to remove workspace volume\n")
-		}
-	}
+	runtime.removeServices(errorLog)
 
-	if runtime.networkAttached {
-		success, err := runtime.RunCommand(runtime.DockerCommand, []string{"network", "disconnect", "-f", runtime.network, runtime.hostname}, nil, nil)
-		if err != nil {
-			errorLog.Printf("failed to detach network - %s\n", err)
-		} else if !success {
-			errorLog.Printf("failed to detach network\n")
-		}
-	}
+	runtime.Executor.Cleanup(errorLog)
+}
 
-	if runtime.networkCreated {
-		success, err := runtime.RunCommand(runtime.DockerCommand, []string{"network", "rm", runtime.network}, nil, nil)
-		if err != nil {
-			errorLog.Printf("failed to remove network - %s\n", err)
-		} else if !success {
-			errorLog.Printf("failed to remove network\n")
+func (runtime *Runtime) Run(ctx context.Context) (success bool) {
+	ctx, span := tracing.Start(ctx, "runner", tracing.ATTR_PIPELINE.String(runtime.Pipeline.Name))
+	defer func() {
+		if success {
+			tracing.End(span, nil)
+		} else {
+			tracing.End(span, errors.New("pipeline failed"))
 		}
-	}
-}
+	}()
 
-func (runtime *Runtime) Cancel() {
-	runtime.cancelLock.Lock()
-	defer runtime.cancelLock.Unlock()
+	defer runtime.SetPhase(PHASE_FINISHED)
+	defer runtime.Cleanup()
 
-	runtime.canceled = true
-}
+	ctx, cancel := runtime.withCancel(ctx)
+	defer cancel()
 
-func (runtime *Runtime) Run() bool {
-	defer runtime.Cleanup()
+	setupParams, err := runtime.setupParams()
+	if err != nil {
+		runtime.ErrorLog.Subsystem("setup").Printf("resolving params failed - %s - exiting\n", err)
+		return false
+	}
+	runtime.PipelineTimeout, err = runtime.pipelineTimeout(setupParams)
+	if err != nil {
+		runtime.ErrorLog.Subsystem("timeout").Printf("%s - exiting\n", err)
+		return false
+	}
+	err = runtime.selectExecutor(setupParams)
+	if err != nil {
+		runtime.ErrorLog.Subsystem("prepare").Printf("%s - exiting\n", err)
+		return false
+	}
+	if runtime.PipelineTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, runtime.PipelineTimeout)
+		defer cancelTimeout()
+	}
 
 	if strings.TrimSpace(runtime.Pipeline.Headline) != "" {
 		runtime.Log.Subsystem("headline").Println(runtime.Pipeline.Headline)
@@ -183,99 +261,226 @@ func (runtime *Runtime) Run() bool {
 		}
 	}
 
+	dependencies, err := stepDependencies(runtime.Pipeline.Steps)
+	if err != nil {
+		runtime.ErrorLog.Subsystem("steps").Printf("invalid step dependencies - %s - exiting\n", err)
+		return false
+	}
+
+	runtime.SetPhase(PHASE_PREPARE)
 	runtime.Log.Subsystem("prepare").Printf("setting up pipeline %s\n", runtime.Pipeline.Name)
+	_, prepareSpan := tracing.Start(ctx, "prepare")
 	if !runtime.Prepare() {
+		tracing.End(prepareSpan, errors.New("failed to set up pipeline"))
 		runtime.ErrorLog.Subsystem("prepare").Println("failed to set up pipeline - exiting")
 		return false
 	}
+	tracing.End(prepareSpan, nil)
 
 	setupLog := runtime.Log.Subsystem("setup")
 	setupErrorLog := runtime.ErrorLog.Subsystem("setup")
-	success := runtime.RunTask(runtime.Pipeline.Setup.RunConfig, setupLog, setupErrorLog)
-	if success {
+	runtime.SetPhase(PHASE_SETUP)
+	setupCtx, setupSpan := tracing.Start(ctx, "setup")
+	setupResult := runtime.RunTask(setupCtx, runtime.Pipeline.Setup.RunConfig, setupLog, setupErrorLog)
+	if setupResult.Success {
+		tracing.End(setupSpan, nil)
 		setupLog.Subsystem("success").Println("setup done")
+	} else if setupResult.Canceled {
+		tracing.End(setupSpan, ErrCanceled)
+		setupLog.Subsystem("cancel").Println("setup canceled")
+		return false
+	} else if setupResult.TimedOut {
+		tracing.End(setupSpan, errors.New("setup timed out"))
+		setupLog.Subsystem("timeout").Println("setup timed out")
+		runtime.pipelineTimedOut(ctx)
+		return false
 	} else {
+		tracing.End(setupSpan, errors.New("setup failed"))
 		setupLog.Subsystem("failure").Println("setup failed")
 		return false
 	}
 
-	pipelineSuccess := true
-	if runtime.Pipeline.Facts == nil {
-		runtime.Pipeline.Facts = make(map[string]schema.Fact)
+	pipelineSuccess, aborted := runtime.RunSteps(ctx, dependencies)
+	if aborted {
+		return false
 	}
 
-	stageStatuses := make(map[string]string)
+	if pipelineSuccess {
+		_, cacheSpan := tracing.Start(ctx, "save caches")
+		runtime.SaveCaches()
+		tracing.End(cacheSpan, nil)
 
-	stepCount := len(runtime.Pipeline.Steps)
-	for i, step := range runtime.Pipeline.Steps {
-		stepNumber := i + 1
-		stepLog := runtime.Log.Subsystem("step").Subsystem(strconv.Itoa(stepNumber))
-		stepErrorLog := runtime.ErrorLog.Subsystem("step").Subsystem(strconv.Itoa(stepNumber))
+		runtime.Log.Subsystem("success").Println("pipeline finished successfully")
+	} else {
+		runtime.Log.Subsystem("failure").Println("pipeline finished unsuccessfully")
+	}
+	return pipelineSuccess
+}
 
-		if !runtime.CheckHealth() {
-			runtime.ErrorLog.Subsystem("signal").Println("pipeline execution canceled - exiting")
-			return false
-		}
+// TaskResult is the outcome of a task container, ExitCode is -1 if the container could not be run.
+type TaskResult struct {
+	Success  bool
+	TimedOut bool
+	// Canceled is true if the task has been stopped because the pipeline has been canceled.
+	Canceled bool
+	ExitCode int
+	// Attempts is the number of times the task has been run, see RunTaskWithRetry.
+	Attempts int
+}
 
-		stage := step.Stage
-		if stage == "" {
-			stage = schema.DEFAULT_STAGE
-		}
+// reservedParams are step params which configure the runner instead of being passed to the task container.
+var reservedParams = map[string]bool{
+	PARAM_NEEDS:            true,
+	PARAM_TIMEOUT:          true,
+	PARAM_PIPELINE_TIMEOUT: true,
+	PARAM_RUNTIME_ENV:      true,
+	PARAM_RETRY:            true,
+	PARAM_RETRY_BACKOFF:    true,
+	PARAM_RETRY_EXIT_CODES: true,
+	PARAM_SERVICE:          true,
+	PARAM_READY:            true,
+	PARAM_READY_TIMEOUT:    true,
+	PARAM_CPUS:             true,
+	PARAM_MEMORY:           true,
+	PARAM_PIDS_LIMIT:       true,
+	PARAM_READ_ONLY:        true,
+	PARAM_TMPFS:            true,
+}
 
-		if _, ok := stageStatuses[stage]; !ok {
-			stageStatuses[stage] = "success"
-		}
-		runtime.Pipeline.Facts["status"] = []string{stageStatuses[stage]}
+// RunTask runs a task using the executor.
+func (runtime *Runtime) RunTask(ctx context.Context, config schema.RunConfig, log, errorLog logs.LogWriter) TaskResult {
+	task, _, ok := runtime.prepareTask(ctx, config, errorLog)
+	if !ok {
+		return TaskResult{ExitCode: -1}
+	}
+	if isCanceled(ctx) {
+		return TaskResult{Canceled: true, ExitCode: -1}
+	}
+
+	return runtime.Executor.RunTask(ctx, task, log, errorLog)
+}
+
+// setupParams resolves the params of the setup, which contain the reserved params configuring the whole pipeline.
+func (runtime *Runtime) setupParams() (map[string]string, error) {
+	runtime.VarLock.Lock()
+	resolvedConfig, _, _, err := runtime.Pipeline.Setup.RunConfig.Resolve(runtime.Pipeline.Env, runtime.Vars)
+	runtime.VarLock.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	return resolvedConfig.Params, nil
+}
 
-		runtime.VarLock.Lock()
+// prepareTask resolves the image, params and options of a task, logging errors to errorLog.
+func (runtime *Runtime) prepareTask(ctx context.Context, config schema.RunConfig, errorLog logs.LogWriter) (task Task, params map[string]string, ok bool) {
+	if config.Task == TASK_APPROVAL || config.Task == TASK_CACHE {
+		errorLog.Printf("failed to run task - %s is only supported for pipeline steps\n", config.Task)
+		return
+	}
 
-		conditions.ApplyDefaults(&step.When, stepDefaultConditions)
+	image, trusted, err := runtime.resolveImage(config.Task)
+	if err != nil {
+		errorLog.Printf("failed to run task - %s\n", err)
+		return
+	}
 
-		success, err := conditions.Check(runtime.Pipeline.Facts, step.When, runtime.Pipeline.Env, runtime.Vars)
+	var env []string
 
-		runtime.VarLock.Unlock()
+	runtime.VarLock.Lock()
+	resolvedConfig, missingEnv, _, err := config.Resolve(runtime.Pipeline.Env, runtime.Vars)
+	runtime.VarLock.Unlock()
 
-		if err != nil {
-			stepErrorLog.Printf("checking conditions failed - %s\n", err)
+	if err != nil {
+		errorLog.Printf("failed to run task - resolving params failed - %s\n", err)
+		return
+	}
+	if len(missingEnv) > 0 {
+		errorLog.Printf("failed to run task - missing environment variables %s\n", strings.Join(missingEnv, ", "))
+		return
+	}
+
+	if runtime.ForwardProxy {
+		for _, key := range proxyEnv {
+			if value := os.Getenv(key); value != "" {
+				env = append(env, fmt.Sprintf("%s=%s", key, value))
+			}
+			key = strings.ToLower(key)
+			if value := os.Getenv(key); value != "" {
+				env = append(env, fmt.Sprintf("%s=%s", key, value))
+			}
 		}
-		if !success || err != nil {
-			if stage == schema.DEFAULT_STAGE {
-				stepLog.Subsystem("skip").Printf("skipping step %s (%v/%v)\n", step.Name, stepNumber, stepCount)
+		for _, key := range []string{"NO_PROXY", "no_proxy"} {
+			value := os.Getenv(key)
+			if value == "" {
+				value = runtime.hostname
 			} else {
-				stepLog.Subsystem("skip").Printf("skipping step %s [stage %s] (%v/%v)\n", step.Name, stage, stepNumber, stepCount)
+				value += "," + runtime.hostname
 			}
-			continue
+			env = append(env, fmt.Sprintf("%s=%s", key, value))
 		}
+	}
 
-		if stage == schema.DEFAULT_STAGE {
-			stepLog.Printf("running step %s (%v/%v)\n", step.Name, stepNumber, stepCount)
-		} else {
-			stepLog.Printf("running step %s [stage %s] (%v/%v)\n", step.Name, stage, stepNumber, stepCount)
-		}
+	timeout, err := taskTimeout(ctx, resolvedConfig.Params)
+	if err != nil {
+		errorLog.Printf("failed to run task - %s\n", err)
+		return
+	}
 
-		success = runtime.RunTask(step.RunConfig, stepLog, stepErrorLog)
-		if success {
-			stepLog.Subsystem("success").Printf("step %s done\n", step.Name)
-		} else if step.IgnoreFailure {
-			stepLog.Subsystem("success").Printf("step %s done - ignoring failure\n", step.Name)
-		} else {
-			stepLog.Subsystem("failure").Printf("step %s failed\n", step.Name)
-			stageStatuses[stage] = "failure"
-			pipelineSuccess = false
+	paramKeys := make([]string, 0, len(resolvedConfig.Params))
+	for key, value := range resolvedConfig.Params {
+		if reservedParams[key] {
+			continue
+		}
+		if strings.Contains(key, "=") {
+			errorLog.Printf("failed to run task - invalid token '=' in param name '%s'\n", key)
+			return
 		}
+		paramKeys = append(paramKeys, key)
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+	paramValue, err := json.Marshal(paramKeys)
+	if err != nil {
+		errorLog.Printf("failed to run task - error encoding task param keys - %s\n", err)
+	}
+	env = append(env, fmt.Sprintf("REEVE_PARAMS=%s", string(paramValue)))
+	env = append(env, fmt.Sprintf("REEVE_API=%s", runtime.ApiUrl))
+	for _, traceEnv := range tracing.Env(ctx) {
+		env = append(env, traceEnv)
 	}
 
-	if pipelineSuccess {
-		runtime.Log.Subsystem("success").Println("pipeline finished successfully")
-	} else {
-		runtime.Log.Subsystem("failure").Println("pipeline finished unsuccessfully")
+	options, err := runtime.taskOptions(resolvedConfig.Params, trusted)
+	if err != nil {
+		errorLog.Printf("failed to run task - %s\n", err)
+		return
 	}
-	return pipelineSuccess
+
+	var input io.Reader
+	if len(resolvedConfig.Input) > 0 {
+		input = strings.NewReader(resolvedConfig.Input)
+	}
+
+	directory := ""
+	if len(resolvedConfig.Directory) > 0 {
+		directory = "/" + strings.TrimPrefix(resolvedConfig.Directory, "/")
+	}
+
+	return Task{
+		Image:     image,
+		Command:   resolvedConfig.Command,
+		Env:       env,
+		Input:     input,
+		Directory: directory,
+		User:      resolvedConfig.User,
+		Trusted:   trusted,
+		Timeout:   timeout,
+		Options:   options,
+	}, resolvedConfig.Params, true
 }
 
-func (runtime *Runtime) RunTask(config schema.RunConfig, log, errorLog logs.LogWriter) bool {
-	image := config.Task
-	var trusted bool
+// resolveImage resolves the task domain of a task and returns its image and whether it is trusted.
+func (runtime *Runtime) resolveImage(task string) (image string, trusted bool, err error) {
+	image = task
 
 	if strings.HasPrefix(image, "@") {
 		var found bool
@@ -297,14 +502,12 @@ func (runtime *Runtime) RunTask(config schema.RunConfig, log, errorLog logs.LogW
 		}
 
 		if !found {
-			errorLog.Printf("failed to run task - unknown task domain %s\n", strings.SplitN(image[1:], "/", 2)[0])
-			return false
+			return "", false, fmt.Errorf("unknown task domain %s", strings.SplitN(image[1:], "/", 2)[0])
 		}
 	}
 
 	if image == "" {
-		errorLog.Printf("failed to run task - task image missing\n")
-		return false
+		return "", false, fmt.Errorf("task image missing")
 	}
 
 	if !trusted {
@@ -321,97 +524,47 @@ func (runtime *Runtime) RunTask(config schema.RunConfig, log, errorLog logs.LogW
 		}
 	}
 
-	args := []string{
-		"run",
-		"--rm",
-		"-i",
-		"-v", fmt.Sprintf("%s:/reeve", runtime.workspaceVolume),
-		"--network", runtime.network,
-	}
-
-	runtime.VarLock.Lock()
-	resolvedConfig, missingEnv, _, err := config.Resolve(runtime.Pipeline.Env, runtime.Vars)
-	runtime.VarLock.Unlock()
+	return image, trusted, nil
+}
 
-	if err != nil {
-		errorLog.Printf("failed to run task - resolving params failed - %s\n", err)
-		return false
-	}
-	if len(missingEnv) > 0 {
-		errorLog.Printf("failed to run task - missing environment variables %s\n", strings.Join(missingEnv, ", "))
+// pipelineTrusted returns true if the setup and all steps of the pipeline run trusted tasks.
+func (runtime *Runtime) pipelineTrusted() bool {
+	if _, trusted, err := runtime.resolveImage(runtime.Pipeline.Setup.Task); err != nil || !trusted {
 		return false
 	}
-
-	if runtime.ForwardProxy {
-		for _, key := range proxyEnv {
-			if value := os.Getenv(key); value != "" {
-				args = append(args, "-e", fmt.Sprintf("%s=%s", key, value))
-			}
-			key = strings.ToLower(key)
-			if value := os.Getenv(key); value != "" {
-				args = append(args, "-e", fmt.Sprintf("%s=%s", key, value))
-			}
-		}
-		for _, key := range []string{"NO_PROXY", "no_proxy"} {
-			value := os.Getenv(key)
-			if value == "" {
-				value = runtime.hostname
-			} else {
-				value += "," + runtime.hostname
-			}
-			args = append(args, "-e", fmt.Sprintf("%s=%s", key, value))
+	for _, step := range runtime.Pipeline.Steps {
+		if step.Task == TASK_APPROVAL || step.Task == TASK_CACHE {
+			continue
 		}
-	}
-
-	paramKeys := make([]string, 0, len(resolvedConfig.Params))
-	for key, value := range resolvedConfig.Params {
-		if strings.Contains(key, "=") {
-			errorLog.Printf("failed to run task - invalid token '=' in param name '%s'\n", key)
+		if _, trusted, err := runtime.resolveImage(step.Task); err != nil || !trusted {
 			return false
 		}
-		paramKeys = append(paramKeys, key)
-		args = append(args, "-e", fmt.Sprintf("%s=%s", key, value))
-	}
-	paramValue, err := json.Marshal(paramKeys)
-	if err != nil {
-		errorLog.Printf("failed to run task - error encoding task param keys - %s\n", err)
-	}
-	args = append(args, "-e", fmt.Sprintf("REEVE_PARAMS=%s", string(paramValue)))
-	args = append(args, "-e", fmt.Sprintf("REEVE_API=%s", runtime.ApiUrl))
-
-	if trusted {
-		args = append(args, "-v", "/var/run/docker.sock:/var/run/docker.sock")
-	}
-
-	if len(resolvedConfig.Directory) > 0 {
-		args = append(args,
-			"-v", fmt.Sprintf("/%s:/reeve/mount:rw", strings.TrimPrefix(resolvedConfig.Directory, "/")),
-			"-w", "/reeve/mount",
-		)
 	}
+	return true
+}
 
-	if resolvedConfig.User != "" {
-		args = append(args, "-u", resolvedConfig.User)
-	}
+func (runtime *Runtime) SetPhase(phase string) {
+	runtime.phaseLock.Lock()
+	defer runtime.phaseLock.Unlock()
 
-	var input io.Reader
-	if len(resolvedConfig.Input) > 0 {
-		input = strings.NewReader(resolvedConfig.Input)
-	}
+	runtime.phase = phase
+}
 
-	args = append(args, "--name", "reeve-"+uuid.NewString(), image)
+func (runtime *Runtime) Phase() string {
+	runtime.phaseLock.Lock()
+	defer runtime.phaseLock.Unlock()
 
-	if len(resolvedConfig.Command) > 0 {
-		args = append(args, resolvedConfig.Command...)
-	}
+	return runtime.phase
+}
 
-	success, err := runtime.RunCommand(runtime.DockerCommand, args, input, log.Subsystem(">"))
-	if err != nil {
-		errorLog.Printf("failed to run task - %s\n", err)
+// Executing returns true while setup or steps are being run.
+func (runtime *Runtime) Executing() bool {
+	switch runtime.Phase() {
+	case PHASE_STARTING, PHASE_PREPARE, PHASE_CLEANUP, PHASE_FINISHED:
 		return false
+	default:
+		return true
 	}
-
-	return success
 }
 
 func (runtime *Runtime) CheckHealth() bool {
@@ -422,6 +575,12 @@ func (runtime *Runtime) CheckHealth() bool {
 }
 
 func (runtime *Runtime) RunCommand(command string, args []string, stdin io.Reader, log logs.LogWriter) (success bool, err error) {
+	exitCode, err := runtime.RunCommandExitCode(command, args, stdin, log)
+	return err == nil && exitCode == 0, err
+}
+
+// RunCommandExitCode runs a command like RunCommand, but returns the exit code of the command.
+func (runtime *Runtime) RunCommandExitCode(command string, args []string, stdin io.Reader, log logs.LogWriter) (exitCode int, err error) {
 	cmd := exec.Command(command, args...)
 	if stdin != nil {
 		cmd.Stdin = stdin
@@ -439,27 +598,18 @@ func (runtime *Runtime) RunCommand(command string, args []string, stdin io.Reade
 		return
 	}
 	if outReader != nil {
-		err := FilterSensitive(outReader, log, runtime.filters)
+		err := FilterSensitive(outReader, log, runtime.Masker)
 		if err != nil {
 			panic(err)
 		}
 	}
 	err = cmd.Wait()
 	if err != nil {
-		if _, ok := err.(*exec.ExitError); ok {
-			return false, nil
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), nil
 		}
-		return false, err
+		return -1, err
 	}
 
-	return true, nil
-}
-
-func FilterSensitive(r io.Reader, w io.Writer, filters []string) error {
-	return filter.LineFilter(r, w, func(line string) string {
-		for _, f := range filters {
-			line = strings.ReplaceAll(line, f, "*******")
-		}
-		return line
-	})
+	return 0, nil
 }
@@ -0,0 +1,207 @@
+package runtime
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Reserved step params overriding the resource limits of the task container.
+const (
+	PARAM_CPUS       = "REEVE_CPUS"
+	PARAM_MEMORY     = "REEVE_MEMORY"
+	PARAM_PIDS_LIMIT = "REEVE_PIDS_LIMIT"
+)
+
+// PARAM_READ_ONLY is the reserved step param mounting the root filesystem of the task container as read only (true or false).
+const PARAM_READ_ONLY = "REEVE_READ_ONLY"
+
+// PARAM_TMPFS is the reserved step param listing additional tmpfs mounts of the task container (split by spaces).
+const PARAM_TMPFS = "REEVE_TMPFS"
+
+// Options of the security profiles, see ParseSecurityProfile.
+const (
+	SECURITY_NONE              = "none"
+	SECURITY_CAP_DROP          = "cap-drop"
+	SECURITY_NO_NEW_PRIVILEGES = "no-new-privileges"
+	SECURITY_READ_ONLY         = "read-only"
+)
+
+var memoryPattern = regexp.MustCompile(`^[0-9]+[bBkKmMgG]?$`)
+
+// ResourceLimits are passed to docker run, empty values are not limited.
+type ResourceLimits struct {
+	CPUs      string
+	Memory    string
+	PidsLimit string
+}
+
+// SecurityProfile contains the hardening options applied to task containers of a trust level.
+type SecurityProfile struct {
+	// CapDrop drops all capabilities.
+	CapDrop bool
+	// NoNewPrivileges prevents processes from gaining privileges, e.g. using setuid binaries.
+	NoNewPrivileges bool
+	// ReadOnly mounts the root filesystem as read only, /tmp is mounted as tmpfs.
+	ReadOnly bool
+}
+
+// ParseSecurityProfile parses a list of security options split by spaces, none disables all options.
+func ParseSecurityProfile(value string) (profile SecurityProfile, err error) {
+	for _, option := range strings.Fields(value) {
+		switch option {
+		case SECURITY_NONE:
+		case SECURITY_CAP_DROP:
+			profile.CapDrop = true
+		case SECURITY_NO_NEW_PRIVILEGES:
+			profile.NoNewPrivileges = true
+		case SECURITY_READ_ONLY:
+			profile.ReadOnly = true
+		default:
+			return profile, fmt.Errorf("unknown security option %s", option)
+		}
+	}
+	return
+}
+
+// ParseResourceLimits validates resource limits.
+func ParseResourceLimits(cpus, memory, pidsLimit string) (limits ResourceLimits, err error) {
+	if cpus != "" {
+		value, err := strconv.ParseFloat(cpus, 64)
+		if err != nil || value <= 0 {
+			return limits, fmt.Errorf("invalid cpus '%s' - must be a positive number", cpus)
+		}
+	}
+	if memory != "" && !memoryPattern.MatchString(memory) {
+		return limits, fmt.Errorf("invalid memory '%s' - must be a size such as 512m or 2g", memory)
+	}
+	if pidsLimit != "" {
+		value, err := strconv.Atoi(pidsLimit)
+		if err != nil || value <= 0 {
+			return limits, fmt.Errorf("invalid pids limit '%s' - must be a positive number", pidsLimit)
+		}
+	}
+
+	return ResourceLimits{CPUs: cpus, Memory: memory, PidsLimit: pidsLimit}, nil
+}
+
+// TaskOptions contains the resource limits and hardening options of a task container.
+type TaskOptions struct {
+	Limits   ResourceLimits
+	Security SecurityProfile
+	// Tmpfs lists additional tmpfs mounts.
+	Tmpfs []string
+}
+
+// taskOptions returns the resource limits and hardening options of a task depending on whether it is trusted.
+// Step params override the worker defaults, untrusted tasks may only tighten the limits and the security profile.
+func (runtime *Runtime) taskOptions(params map[string]string, trusted bool) (options TaskOptions, err error) {
+	options.Limits, err = ParseResourceLimits(params[PARAM_CPUS], params[PARAM_MEMORY], params[PARAM_PIDS_LIMIT])
+	if err != nil {
+		return
+	}
+
+	if trusted {
+		options.Limits.CPUs = valueOrDefault(options.Limits.CPUs, runtime.TaskLimits.CPUs)
+		options.Limits.Memory = valueOrDefault(options.Limits.Memory, runtime.TaskLimits.Memory)
+		options.Limits.PidsLimit = valueOrDefault(options.Limits.PidsLimit, runtime.TaskLimits.PidsLimit)
+	} else {
+		options.Limits.CPUs = lowerLimit(options.Limits.CPUs, runtime.TaskLimits.CPUs, parseCPUs)
+		options.Limits.Memory = lowerLimit(options.Limits.Memory, runtime.TaskLimits.Memory, parseMemory)
+		options.Limits.PidsLimit = lowerLimit(options.Limits.PidsLimit, runtime.TaskLimits.PidsLimit, parsePidsLimit)
+	}
+
+	options.Security = runtime.UntrustedSecurity
+	if trusted {
+		options.Security = runtime.TrustedSecurity
+	}
+
+	if value := params[PARAM_READ_ONLY]; value != "" {
+		readOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid %s '%s' - must be true or false", PARAM_READ_ONLY, value)
+		}
+		if trusted || readOnly {
+			options.Security.ReadOnly = readOnly
+		}
+	}
+
+	for _, path := range strings.Fields(params[PARAM_TMPFS]) {
+		if !strings.HasPrefix(path, "/") || strings.Contains(path, ",") {
+			return options, fmt.Errorf("invalid %s path '%s' - must be absolute", PARAM_TMPFS, path)
+		}
+		options.Tmpfs = append(options.Tmpfs, path)
+	}
+
+	return options, nil
+}
+
+// Args returns the docker run options, which are also supported by Podman.
+func (options TaskOptions) Args() []string {
+	var args []string
+	if options.Limits.CPUs != "" {
+		args = append(args, "--cpus", options.Limits.CPUs)
+	}
+	if options.Limits.Memory != "" {
+		args = append(args, "--memory", options.Limits.Memory, "--memory-swap", options.Limits.Memory)
+	}
+	if options.Limits.PidsLimit != "" {
+		args = append(args, "--pids-limit", options.Limits.PidsLimit)
+	}
+
+	if options.Security.CapDrop {
+		args = append(args, "--cap-drop", "ALL")
+	}
+	if options.Security.NoNewPrivileges {
+		args = append(args, "--security-opt", "no-new-privileges")
+	}
+	if options.Security.ReadOnly {
+		args = append(args, "--read-only", "--tmpfs", "/tmp")
+	}
+	for _, path := range options.Tmpfs {
+		args = append(args, "--tmpfs", path)
+	}
+
+	return args
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// lowerLimit returns the lower of two validated limits, empty limits are unlimited.
+func lowerLimit(value, limit string, parse func(string) float64) string {
+	if value == "" || (limit != "" && parse(limit) < parse(value)) {
+		return limit
+	}
+	return value
+}
+
+func parseCPUs(value string) float64 {
+	cpus, _ := strconv.ParseFloat(value, 64)
+	return cpus
+}
+
+func parsePidsLimit(value string) float64 {
+	pidsLimit, _ := strconv.Atoi(value)
+	return float64(pidsLimit)
+}
+
+// parseMemory returns the size in bytes of a memory limit matching memoryPattern.
+func parseMemory(value string) float64 {
+	unit := 1.0
+	switch strings.ToLower(value[len(value)-1:]) {
+	case "k":
+		unit = 1 << 10
+	case "m":
+		unit = 1 << 20
+	case "g":
+		unit = 1 << 30
+	}
+	size, _ := strconv.ParseFloat(strings.TrimRight(value, "bBkKmMgG"), 64)
+	return size * unit
+}
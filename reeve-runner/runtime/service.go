@@ -0,0 +1,178 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/reeveci/reeve-lib/logs"
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// PARAM_SERVICE is the reserved step param turning a step into a service, its value is the hostname of the service on the pipeline network.
+// Services keep running in the background until the pipeline has finished.
+const PARAM_SERVICE = "REEVE_SERVICE"
+
+// PARAM_READY is the reserved step param containing a shell command which is run inside the service container until it succeeds.
+const PARAM_READY = "REEVE_READY"
+
+// PARAM_READY_TIMEOUT is the reserved step param limiting the time to wait for a service to become ready (default 60s).
+const PARAM_READY_TIMEOUT = "REEVE_READY_TIMEOUT"
+
+const DEFAULT_READY_TIMEOUT = 60 * time.Second
+const READY_INTERVAL = 2 * time.Second
+
+var serviceAliasPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+
+// isService returns true if a step declares a service.
+func isService(step schema.Step) bool {
+	_, ok := step.Params[PARAM_SERVICE]
+	return ok
+}
+
+// RunService starts a service container and waits until it is ready.
+func (runtime *Runtime) RunService(ctx context.Context, step schema.Step, log, errorLog logs.LogWriter) bool {
+	executor, err := runtime.containerExecutor()
+	if err != nil {
+		errorLog.Printf("failed to start service - %s\n", err)
+		return false
+	}
+
+	task, params, ok := runtime.prepareTask(ctx, step.RunConfig, errorLog)
+	if !ok {
+		return false
+	}
+
+	alias := params[PARAM_SERVICE]
+	if len(alias) > 63 || !serviceAliasPattern.MatchString(alias) {
+		errorLog.Printf("failed to start service - invalid %s '%s' - must be a hostname\n", PARAM_SERVICE, alias)
+		return false
+	}
+	if task.Input != nil {
+		errorLog.Println("failed to start service - input is not supported for services")
+		return false
+	}
+
+	readyTimeout := DEFAULT_READY_TIMEOUT
+	if value := params[PARAM_READY_TIMEOUT]; value != "" {
+		readyTimeout, err = time.ParseDuration(value)
+		if err != nil || readyTimeout <= 0 {
+			errorLog.Printf("failed to start service - invalid %s '%s' - must be a positive duration\n", PARAM_READY_TIMEOUT, value)
+			return false
+		}
+	}
+
+	name := "reeve-" + uuid.NewString()
+	args := append([]string{"run", "-d", "--network-alias", alias}, executor.containerArgs(task)...)
+	args = append(args, "--name", name, task.Image)
+	args = append(args, task.Command...)
+
+	runtime.serviceLock.Lock()
+	runtime.services = append(runtime.services, name)
+	runtime.serviceLock.Unlock()
+
+	success, err := runtime.RunCommand(executor.Command, args, nil, log.Subsystem(">"))
+	if err != nil {
+		errorLog.Printf("failed to start service - %s\n", err)
+		return false
+	}
+	if !success {
+		errorLog.Println("failed to start service")
+		return false
+	}
+
+	runtime.serviceLogs.Add(1)
+	go func() {
+		defer runtime.serviceLogs.Done()
+
+		serviceLog := runtime.Log.Subsystem("service").Subsystem(alias)
+		runtime.RunCommand(executor.Command, []string{"logs", "-f", name}, nil, serviceLog)
+	}()
+
+	ready := params[PARAM_READY]
+	if ready == "" {
+		log.Printf("service %s started\n", alias)
+		return true
+	}
+
+	// the wait is bounded by the step timeout and the pipeline deadline as well, see taskTimeout
+	if task.Timeout > 0 && task.Timeout < readyTimeout {
+		readyTimeout = task.Timeout
+	}
+	readyCtx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	log.Printf("waiting for service %s to become ready\n", alias)
+	for {
+		if !executor.running(name) {
+			errorLog.Printf("service %s exited before becoming ready\n", alias)
+			return false
+		}
+
+		err := exec.CommandContext(readyCtx, executor.Command, "exec", name, "sh", "-c", ready).Run()
+		if err == nil {
+			log.Printf("service %s is ready\n", alias)
+			return true
+		}
+
+		var exitErr *exec.ExitError
+		if readyCtx.Err() == nil && !errors.As(err, &exitErr) {
+			errorLog.Printf("failed to check readiness of service %s - %s\n", alias, err)
+			return false
+		}
+
+		if readyCtx.Err() == nil {
+			timer := time.NewTimer(READY_INTERVAL)
+			select {
+			case <-timer.C:
+				continue
+			case <-readyCtx.Done():
+				timer.Stop()
+			}
+		}
+
+		if isCanceled(ctx) {
+			errorLog.Printf("waiting for service %s canceled\n", alias)
+		} else {
+			errorLog.Printf("service %s did not become ready within %s\n", alias, readyTimeout)
+		}
+		return false
+	}
+}
+
+func (executor *CLIExecutor) running(name string) bool {
+	output, err := exec.Command(executor.Command, "inspect", "-f", "{{.State.Running}}", name).Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+// removeServices removes all service containers and waits for their logs to be written.
+func (runtime *Runtime) removeServices(errorLog logs.LogWriter) {
+	runtime.serviceLock.Lock()
+	services := runtime.services
+	runtime.services = nil
+	runtime.serviceLock.Unlock()
+
+	if len(services) == 0 {
+		return
+	}
+
+	executor, err := runtime.containerExecutor()
+	if err != nil {
+		errorLog.Printf("failed to remove services - %s\n", err)
+		return
+	}
+
+	for _, name := range services {
+		// containers which failed to be created are not reported
+		_, err := runtime.RunCommand(executor.Command, []string{"rm", "-f", name}, nil, nil)
+		if err != nil {
+			errorLog.Printf("failed to remove service container - %s\n", err)
+		}
+	}
+
+	runtime.serviceLogs.Wait()
+}
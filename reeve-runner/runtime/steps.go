@@ -0,0 +1,293 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/reeveci/reeve-lib/conditions"
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-common/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PARAM_NEEDS is the reserved step param listing the names of earlier steps a step depends on (split by spaces).
+// Steps without it depend on the previous step, an empty list lets a step start right after the setup.
+const PARAM_NEEDS = "REEVE_NEEDS"
+
+// stepDependencies returns the indices of the steps each step depends on.
+func stepDependencies(steps []schema.Step) ([][]int, error) {
+	dependencies := make([][]int, len(steps))
+	names := make(map[string][]int)
+
+	for i, step := range steps {
+		raw, ok := step.Params[PARAM_NEEDS]
+		if !ok {
+			if i > 0 {
+				dependencies[i] = []int{i - 1}
+			}
+		} else {
+			value, ok := raw.(string)
+			if raw != nil && !ok {
+				return nil, fmt.Errorf("%s of step %s must be a string", PARAM_NEEDS, step.Name)
+			}
+
+			for _, name := range strings.Fields(value) {
+				indices, ok := names[name]
+				if !ok {
+					return nil, fmt.Errorf("step %s needs unknown step %s - only earlier steps can be referenced", step.Name, name)
+				}
+				dependencies[i] = append(dependencies[i], indices...)
+			}
+		}
+
+		names[step.Name] = append(names[step.Name], i)
+	}
+
+	return dependencies, nil
+}
+
+// RunSteps runs the pipeline steps once their dependencies are done, running up to Parallelism steps at once.
+// Once the pipeline has been canceled, the remaining steps are checked with the status canceled.
+// aborted is true if the pipeline has been canceled or timed out.
+func (runtime *Runtime) RunSteps(ctx context.Context, dependencies [][]int) (success, aborted bool) {
+	steps := runtime.Pipeline.Steps
+	stepCount := len(steps)
+
+	// ancestors contains all steps a step depends on directly or indirectly.
+	ancestors := make([]map[int]bool, stepCount)
+	for i := range steps {
+		ancestors[i] = make(map[int]bool)
+		for _, dependency := range dependencies[i] {
+			ancestors[i][dependency] = true
+			for ancestor := range ancestors[dependency] {
+				ancestors[i][ancestor] = true
+			}
+		}
+	}
+
+	var lock sync.Mutex
+	success = true
+	failed := make([]bool, stepCount)
+	running := make(map[int]bool)
+
+	setRunning := func(stepNumber int, isRunning bool) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if isRunning {
+			running[stepNumber] = true
+		} else {
+			delete(running, stepNumber)
+		}
+		if len(running) == 0 {
+			return
+		}
+
+		numbers := make([]int, 0, len(running))
+		for number := range running {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		labels := make([]string, len(numbers))
+		for i, number := range numbers {
+			labels[i] = strconv.Itoa(number)
+		}
+		runtime.SetPhase(fmt.Sprintf("%s %s/%v", PHASE_STEP, strings.Join(labels, ","), stepCount))
+	}
+
+	var timeoutOnce sync.Once
+	timedOut := func() bool {
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return false
+		}
+		timeoutOnce.Do(func() {
+			runtime.pipelineTimedOut(ctx)
+		})
+		return true
+	}
+
+	var cancelOnce sync.Once
+	logCanceled := func() {
+		cancelOnce.Do(func() {
+			runtime.ErrorLog.Subsystem("signal").Println("pipeline execution canceled - skipping remaining steps")
+		})
+	}
+
+	slots := make(chan struct{}, runtime.Parallelism)
+	finished := make([]chan struct{}, stepCount)
+	for i := range steps {
+		finished[i] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for i, step := range steps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(finished[i])
+
+			for _, dependency := range dependencies[i] {
+				<-finished[dependency]
+			}
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			if timedOut() {
+				return
+			}
+
+			stage := step.Stage
+			if stage == "" {
+				stage = schema.DEFAULT_STAGE
+			}
+
+			status := "success"
+			lock.Lock()
+			for ancestor := range ancestors[i] {
+				ancestorStage := steps[ancestor].Stage
+				if ancestorStage == "" {
+					ancestorStage = schema.DEFAULT_STAGE
+				}
+				if failed[ancestor] && ancestorStage == stage {
+					status = "failure"
+					break
+				}
+			}
+			lock.Unlock()
+
+			// once the pipeline has been canceled, only steps running on cancellation are run
+			stepCtx := ctx
+			if isCanceled(ctx) {
+				logCanceled()
+				status = STATUS_CANCELED
+
+				var cancel context.CancelFunc
+				stepCtx, cancel = detach(ctx)
+				defer cancel()
+			}
+
+			stepNumber := i + 1
+			setRunning(stepNumber, true)
+			stepSuccess := runtime.RunStep(stepCtx, stepNumber, step, stage, status)
+			setRunning(stepNumber, false)
+
+			if !stepSuccess {
+				lock.Lock()
+				failed[i] = true
+				success = false
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if timedOut() {
+		return false, true
+	}
+	if isCanceled(ctx) {
+		logCanceled()
+		return false, true
+	}
+	return success, false
+}
+
+// RunStep checks the conditions of a step and runs it, returning false if the step failed without ignoring failures.
+// status is the status of the stage, considering only the steps the step depends on.
+func (runtime *Runtime) RunStep(ctx context.Context, stepNumber int, step schema.Step, stage, status string) bool {
+	stepCount := len(runtime.Pipeline.Steps)
+	stepLog := runtime.Log.Subsystem("step").Subsystem(strconv.Itoa(stepNumber))
+	stepErrorLog := runtime.ErrorLog.Subsystem("step").Subsystem(strconv.Itoa(stepNumber))
+
+	facts := make(map[string]schema.Fact, len(runtime.Pipeline.Facts)+1)
+	for key, value := range runtime.Pipeline.Facts {
+		facts[key] = value
+	}
+	facts["status"] = []string{status}
+
+	stepCtx, stepSpan := tracing.Start(ctx, fmt.Sprintf("step %v: %s", stepNumber, step.Name),
+		attribute.Int("reeve.step", stepNumber),
+		attribute.String("reeve.stage", stage),
+	)
+
+	runtime.VarLock.Lock()
+
+	conditions.ApplyDefaults(&step.When, stepDefaultConditions)
+
+	success, err := conditions.Check(facts, step.When, runtime.Pipeline.Env, runtime.Vars)
+
+	runtime.VarLock.Unlock()
+
+	if err != nil {
+		stepErrorLog.Printf("checking conditions failed - %s\n", err)
+	}
+	if !success || err != nil {
+		if stage == schema.DEFAULT_STAGE {
+			stepLog.Subsystem("skip").Printf("skipping step %s (%v/%v)\n", step.Name, stepNumber, stepCount)
+		} else {
+			stepLog.Subsystem("skip").Printf("skipping step %s [stage %s] (%v/%v)\n", step.Name, stage, stepNumber, stepCount)
+		}
+		stepSpan.SetAttributes(attribute.Bool("reeve.skipped", true))
+		tracing.End(stepSpan, err)
+		return true
+	}
+
+	if stage == schema.DEFAULT_STAGE {
+		stepLog.Printf("running step %s (%v/%v)\n", step.Name, stepNumber, stepCount)
+	} else {
+		stepLog.Printf("running step %s [stage %s] (%v/%v)\n", step.Name, stage, stepNumber, stepCount)
+	}
+
+	result := TaskResult{Attempts: 1}
+	if step.Task == TASK_APPROVAL {
+		result.Success = runtime.RunApproval(stepCtx, stepNumber, step, stepLog, stepErrorLog)
+	} else if step.Task == TASK_CACHE {
+		result.Success = runtime.RunCache(step, stepLog, stepErrorLog)
+	} else if isService(step) {
+		result.Success = runtime.RunService(stepCtx, step, stepLog, stepErrorLog)
+	} else {
+		result = runtime.RunTaskWithRetry(stepCtx, step.RunConfig, stepLog, stepErrorLog)
+	}
+	stepSpan.SetAttributes(attribute.Int("reeve.attempts", result.Attempts))
+	if result.TimedOut {
+		stepSpan.SetAttributes(attribute.Bool("reeve.timed_out", true))
+	}
+
+	attemptInfo := ""
+	if result.Attempts > 1 {
+		attemptInfo = fmt.Sprintf(" after %v attempts", result.Attempts)
+	}
+
+	switch {
+	case result.Success:
+		tracing.End(stepSpan, nil)
+		stepLog.Subsystem("success").Printf("step %s done%s\n", step.Name, attemptInfo)
+		return true
+	case result.Canceled:
+		tracing.End(stepSpan, fmt.Errorf("step %s canceled", step.Name))
+		stepLog.Subsystem("cancel").Printf("step %s canceled%s\n", step.Name, attemptInfo)
+		return false
+	case result.TimedOut && step.IgnoreFailure:
+		tracing.End(stepSpan, nil)
+		stepLog.Subsystem("timeout").Printf("step %s timed out%s - ignoring failure\n", step.Name, attemptInfo)
+		return true
+	case result.TimedOut:
+		tracing.End(stepSpan, fmt.Errorf("step %s timed out", step.Name))
+		stepLog.Subsystem("timeout").Printf("step %s timed out%s\n", step.Name, attemptInfo)
+		return false
+	case step.IgnoreFailure:
+		stepSpan.SetAttributes(attribute.Bool("reeve.ignored_failure", true))
+		tracing.End(stepSpan, nil)
+		stepLog.Subsystem("success").Printf("step %s done%s - ignoring failure\n", step.Name, attemptInfo)
+		return true
+	default:
+		tracing.End(stepSpan, fmt.Errorf("step %s failed", step.Name))
+		stepLog.Subsystem("failure").Printf("step %s failed%s\n", step.Name, attemptInfo)
+		return false
+	}
+}
@@ -0,0 +1,69 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PARAM_TIMEOUT is the reserved step param limiting the duration of a task (e.g. 10m).
+// It is not passed to the task container.
+const PARAM_TIMEOUT = "REEVE_TIMEOUT"
+
+// PARAM_PIPELINE_TIMEOUT is the reserved setup param limiting the duration of the whole pipeline (e.g. 1h).
+// It may only shorten the pipeline timeout configured on the worker.
+const PARAM_PIPELINE_TIMEOUT = "REEVE_PIPELINE_TIMEOUT"
+
+// taskTimeout returns the time a task may run, limited by the timeout param and the pipeline deadline, 0 means unlimited.
+func taskTimeout(ctx context.Context, params map[string]string) (time.Duration, error) {
+	var timeout time.Duration
+
+	if value, ok := params[PARAM_TIMEOUT]; ok && value != "" {
+		var err error
+		timeout, err = time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return 0, fmt.Errorf("invalid %s '%s' - must be a positive duration", PARAM_TIMEOUT, value)
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			remaining = time.Nanosecond
+		}
+		if timeout == 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+
+	return timeout, nil
+}
+
+// pipelineTimeout returns the time the pipeline may run, limited by the setup param and the worker, 0 means unlimited.
+func (runtime *Runtime) pipelineTimeout(setupParams map[string]string) (time.Duration, error) {
+	value := setupParams[PARAM_PIPELINE_TIMEOUT]
+	if value == "" {
+		return runtime.PipelineTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid %s '%s' - must be a positive duration", PARAM_PIPELINE_TIMEOUT, value)
+	}
+
+	if runtime.PipelineTimeout > 0 && runtime.PipelineTimeout < timeout {
+		return runtime.PipelineTimeout, nil
+	}
+	return timeout, nil
+}
+
+// pipelineTimedOut returns true and logs the timeout if the pipeline deadline has been exceeded.
+func (runtime *Runtime) pipelineTimedOut(ctx context.Context) bool {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+
+	runtime.ErrorLog.Subsystem("timeout").Printf("pipeline timed out after %s\n", runtime.PipelineTimeout)
+	return true
+}
@@ -2,11 +2,16 @@ package activity
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-common/mask"
+	"github.com/reeveci/reeve/reeve-common/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func NewRuntimeActivity(workerGroup string, timeout time.Duration, notifications chan<- schema.PipelineStatus) *RuntimeActivity {
@@ -15,6 +20,7 @@ func NewRuntimeActivity(workerGroup string, timeout time.Duration, notifications
 		Timeout:       timeout,
 		status:        make(map[string]*RuntimeStatus),
 		notifications: notifications,
+		changed:       make(chan struct{}),
 	}
 }
 
@@ -22,21 +28,35 @@ type RuntimeActivity struct {
 	lock sync.Mutex
 
 	Timeout time.Duration
+	// LogLimits apply to the logs of each activity.
+	LogLimits LogLimits
 
 	workerGroup   string
 	status        map[string]*RuntimeStatus
 	notifications chan<- schema.PipelineStatus
+	changed       chan struct{}
 }
 
 type PipelineActivity struct {
 	schema.Pipeline
 	ActivityID string
+	// SecretSources contains the resolve plugin of each secret env resolved by plugins, so that secrets can be restored exactly.
+	// Secret env missing here has been defined by the pipeline.
+	SecretSources map[string]string
 }
 
-func (r *RuntimeActivity) RegisterPipeline(pipeline schema.Pipeline) (activity PipelineActivity) {
+// RegisterPipeline registers an enqueued pipeline.
+// The pipeline is traced as child of the span in ctx until it finishes.
+func (r *RuntimeActivity) RegisterPipeline(ctx context.Context, pipeline schema.Pipeline) (activity PipelineActivity) {
 	activity.Pipeline = pipeline
 	activity.ActivityID = uuid.NewString()
 
+	_, span := tracing.Start(ctx, "pipeline",
+		tracing.ATTR_WORKER_GROUP.String(r.workerGroup),
+		tracing.ATTR_ACTIVITY.String(activity.ActivityID),
+		tracing.ATTR_PIPELINE.String(pipeline.Name),
+	)
+
 	defer r.NotifyUpdate(activity.ActivityID)
 
 	r.lock.Lock()
@@ -44,7 +64,7 @@ func (r *RuntimeActivity) RegisterPipeline(pipeline schema.Pipeline) (activity P
 
 	status := RuntimeStatus{
 		PipelineStatus: schema.PipelineStatus{
-			Pipeline:    censorSecrets(pipeline),
+			Pipeline:    CensorSecrets(pipeline),
 			WorkerGroup: r.workerGroup,
 			ActivityID:  activity.ActivityID,
 
@@ -54,19 +74,25 @@ func (r *RuntimeActivity) RegisterPipeline(pipeline schema.Pipeline) (activity P
 		notifyTimeout: func() {
 			r.NotifyUpdate(activity.ActivityID)
 		},
+
+		secrets: secretValues(pipeline),
+
+		span: span,
 	}
 	status.Result.ExitCode = -1
+	status.StartPhase("queue wait")
 
 	r.status[activity.ActivityID] = &status
 	return
 }
 
-func censorSecrets(pipeline schema.Pipeline) schema.Pipeline {
+// CensorSecrets replaces the values of secret env with a placeholder.
+func CensorSecrets(pipeline schema.Pipeline) schema.Pipeline {
 	censoredEnv := make(map[string]schema.Env, len(pipeline.Env))
 
 	for key, env := range pipeline.Env {
 		if env.Secret {
-			env.Value = "*******"
+			env.Value = mask.MASK
 		}
 
 		censoredEnv[key] = env
@@ -76,6 +102,16 @@ func censorSecrets(pipeline schema.Pipeline) schema.Pipeline {
 	return pipeline
 }
 
+// secretValues returns the values of secret env, which are redacted from uploaded logs.
+func secretValues(pipeline schema.Pipeline) (secrets []string) {
+	for _, env := range pipeline.Env {
+		if env.Secret && env.Value != "" {
+			secrets = append(secrets, env.Value)
+		}
+	}
+	return
+}
+
 func (r *RuntimeActivity) Status(id string) *RuntimeStatus {
 	r.lock.Lock()
 	defer r.lock.Unlock()
@@ -94,25 +130,113 @@ func (r *RuntimeActivity) NotifyUpdate(id string) {
 
 	status.Lock()
 	if status.Finished() {
-		if status.Logs != nil {
-			status.Logs.Close()
-		}
+		status.closeLogs()
+
+		status.endTrace()
 
 		delete(r.status, id)
 	}
 	notification := status.PipelineStatus
 	status.Unlock()
 
+	close(r.changed)
+	r.changed = make(chan struct{})
+
 	r.notifications <- notification
 }
 
+// Running returns the number of activities which have been acknowledged by a worker and did not finish yet.
+func (r *RuntimeActivity) Running() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.running()
+}
+
+func (r *RuntimeActivity) running() (count int) {
+	for _, status := range r.status {
+		status.Lock()
+		if status.Active() {
+			count += 1
+		}
+		status.Unlock()
+	}
+	return
+}
+
+// WaitForCapacity blocks until less than limit activities are running or ctx is done.
+// A limit of 0 or less means unlimited.
+func (r *RuntimeActivity) WaitForCapacity(ctx context.Context, limit int) bool {
+	for {
+		r.lock.Lock()
+		running := r.running()
+		changed := r.changed
+		r.lock.Unlock()
+
+		if limit <= 0 || running < limit {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		}
+	}
+}
+
 type RuntimeStatus struct {
 	schema.PipelineStatus
 
 	notifyTimeout func()
 
+	// secrets contains the resolved secret env values of the pipeline, they are never part of the status.
+	secrets   []string
+	logWriter *LogWriter
+
+	// Approval is set while an approval step is pending.
+	Approval *Approval
+
 	sync.Mutex
 	cancel context.CancelFunc
+
+	span, phase trace.Span
+}
+
+// TraceContext returns the span context of the pipeline span.
+func (r *RuntimeStatus) TraceContext() trace.SpanContext {
+	return r.span.SpanContext()
+}
+
+// StartPhase ends the current phase and starts tracing the next one.
+func (r *RuntimeStatus) StartPhase(name string) {
+	r.EndPhase(nil)
+
+	_, r.phase = tracing.StartFrom(r.span.SpanContext(), name)
+}
+
+// EndPhase ends the current phase, recording err if it is not nil.
+func (r *RuntimeStatus) EndPhase(err error) {
+	if r.phase != nil {
+		tracing.End(r.phase, err)
+		r.phase = nil
+	}
+}
+
+func (r *RuntimeStatus) endTrace() {
+	var err error
+	switch r.Status {
+	case schema.STATUS_TIMEOUT:
+		err = fmt.Errorf("activity timed out")
+	case schema.STATUS_FAILED:
+		err = fmt.Errorf("pipeline failed with exit code %v", r.Result.ExitCode)
+	case STATUS_CANCELED:
+		err = fmt.Errorf("pipeline canceled")
+	}
+
+	r.EndPhase(err)
+	r.span.SetAttributes(attribute.String("reeve.status", string(r.Status)))
+	tracing.End(r.span, err)
 }
 
 func (r *RuntimeStatus) ResetTimeout(timeout time.Duration) {
@@ -127,7 +251,8 @@ func (r *RuntimeStatus) ResetTimeout(timeout time.Duration) {
 		if ctx.Err() == context.DeadlineExceeded {
 			r.Lock()
 
-			if r.Running() {
+			if r.Active() && !r.timeoutSuspended() {
+				r.Approval = nil
 				r.Status = schema.STATUS_TIMEOUT
 				r.Unlock()
 
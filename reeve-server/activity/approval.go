@@ -0,0 +1,141 @@
+package activity
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// STATUS_WAITING_APPROVAL is reported while a pipeline is paused by an approval step.
+// The activity timeout is suspended while the worker is waiting for the decision.
+const STATUS_WAITING_APPROVAL schema.Status = "waiting-approval"
+
+// Approval is an approval step of a running pipeline waiting for a decision.
+type Approval struct {
+	Step    int    `json:"step"`
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
+	// Approvers contains the names of the approval tokens which may decide, any approval token may decide if empty.
+	Approvers []string  `json:"approvers,omitempty"`
+	Since     time.Time `json:"since"`
+
+	decision *ApprovalDecision
+	decided  chan struct{}
+	waiters  int
+}
+
+type ApprovalDecision struct {
+	Approved bool   `json:"approved"`
+	By       string `json:"by"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// PendingApproval is an approval of a specific activity.
+type PendingApproval struct {
+	WorkerGroup string `json:"workerGroup"`
+	ActivityID  string `json:"activity"`
+	Pipeline    string `json:"pipeline"`
+	Approval
+}
+
+// MayDecide returns true if the approval token with the given name may decide.
+func (a *Approval) MayDecide(identity string) bool {
+	return len(a.Approvers) == 0 || slices.Contains(a.Approvers, identity)
+}
+
+// Active returns true if the pipeline has been acknowledged by a worker and did not finish yet.
+func (r *RuntimeStatus) Active() bool {
+	return r.Running() || r.Status == STATUS_WAITING_APPROVAL
+}
+
+// RequestApproval pauses the pipeline until the approval step has been decided.
+// Requesting the same step again, e.g. after the worker reconnected, continues waiting for the existing approval.
+// The returned channel is closed once a decision has been made, changed is true if the status has been updated.
+// The caller must hold the lock and call ReleaseApproval once it stops waiting.
+func (r *RuntimeStatus) RequestApproval(approval Approval) (decided <-chan struct{}, changed bool) {
+	if r.Approval == nil || r.Approval.Step != approval.Step {
+		approval.Since = time.Now()
+		approval.decided = make(chan struct{})
+		r.Approval = &approval
+		r.Status = STATUS_WAITING_APPROVAL
+		changed = true
+	}
+
+	r.Approval.waiters += 1
+	r.ClearTimeout()
+	return r.Approval.decided, changed
+}
+
+// ReleaseApproval is called by a waiting worker which stopped waiting for a decision.
+// If the approval has been decided and the decision can be delivered, the pipeline is resumed and the decision is returned.
+// Otherwise, the activity timeout is started again once no worker is waiting anymore.
+// The caller must hold the lock.
+func (r *RuntimeStatus) ReleaseApproval(timeout time.Duration, deliver bool) (decision *ApprovalDecision) {
+	approval := r.Approval
+	if approval == nil {
+		return nil
+	}
+
+	approval.waiters -= 1
+
+	if deliver && approval.decision != nil {
+		decision = approval.decision
+		r.Approval = nil
+		r.Status = schema.STATUS_RUNNING
+		r.ResetTimeout(timeout)
+		return
+	}
+
+	if approval.waiters == 0 {
+		r.ResetTimeout(timeout)
+	}
+	return nil
+}
+
+// Decide records the decision for the pending approval step.
+// The caller must hold the lock.
+func (r *RuntimeStatus) Decide(decision ApprovalDecision) error {
+	if r.Approval == nil || r.Status != STATUS_WAITING_APPROVAL {
+		return fmt.Errorf("activity is not waiting for approval")
+	}
+	if r.Approval.decision != nil {
+		return fmt.Errorf("approval has already been decided")
+	}
+
+	r.Approval.decision = &decision
+	close(r.Approval.decided)
+	return nil
+}
+
+// timeoutSuspended returns true while a worker is waiting for an approval decision.
+func (r *RuntimeStatus) timeoutSuspended() bool {
+	return r.Approval != nil && r.Approval.waiters > 0
+}
+
+// PendingApprovals returns all approvals of the worker group which have not been decided yet.
+func (r *RuntimeActivity) PendingApprovals() []PendingApproval {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var result []PendingApproval
+	for id, status := range r.status {
+		status.Lock()
+		if status.Approval != nil && status.Approval.decision == nil {
+			result = append(result, PendingApproval{
+				WorkerGroup: r.workerGroup,
+				ActivityID:  id,
+				Pipeline:    status.Pipeline.Name,
+				Approval:    *status.Approval,
+			})
+		}
+		status.Unlock()
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Since.Before(result[j].Since)
+	})
+	return result
+}
@@ -0,0 +1,14 @@
+package activity
+
+import "github.com/reeveci/reeve-lib/schema"
+
+// STATUS_CANCELED is reported if the pipeline has been canceled on the worker, e.g. because the worker has been stopped.
+const STATUS_CANCELED schema.Status = "canceled"
+
+// EXIT_CODE_CANCELED is the exit code reported by workers if the runner has been canceled.
+const EXIT_CODE_CANCELED = 3
+
+// Finished returns true if the pipeline has finished, including canceled pipelines.
+func (r *RuntimeStatus) Finished() bool {
+	return r.PipelineStatus.Finished() || r.Status == STATUS_CANCELED
+}
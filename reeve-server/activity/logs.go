@@ -0,0 +1,190 @@
+package activity
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/djherbis/stream"
+	"github.com/reeveci/reeve-lib/streams"
+)
+
+// MAX_PENDING_LINE is the maximum length of an unterminated line which is buffered before being redacted if lines are not limited.
+// Only the part which can not contain the beginning of a secret is written then.
+const MAX_PENDING_LINE = 64 * 1024
+
+const LINE_TRUNCATED_MARKER = " [line truncated]"
+const LOG_TRUNCATED_MARKER = "*** log size limit exceeded, remaining logs have been discarded ***\n"
+
+var ErrLogLimitExceeded = errors.New("log size limit exceeded")
+
+// LogLimits limit the logs stored for each activity.
+type LogLimits struct {
+	// MaxSize is the maximum number of bytes stored, 0 means unlimited.
+	MaxSize int64
+	// MaxLineSize is the maximum length of a single line, longer lines are truncated, 0 means unlimited.
+	MaxLineSize int
+	// Fail fails the activity once the log size limit is exceeded, otherwise the remaining logs are discarded only.
+	Fail bool
+}
+
+// LogWriter redacts secret env values from uploaded logs before writing them to the log stream of an activity.
+// Logs are redacted line by line, an unterminated line is buffered until it is completed or the activity finishes.
+// Once the log size limit is exceeded, a marker is written and all remaining logs are discarded.
+type LogWriter struct {
+	lock     sync.Mutex
+	logs     *streams.StreamProvider
+	limits   LogLimits
+	redactor *redactor
+
+	pending  []byte
+	received int64
+	written  int64
+	skipLine bool
+	limitHit bool
+}
+
+func newLogWriter(logs *streams.StreamProvider, secrets []string, limits LogLimits) *LogWriter {
+	return &LogWriter{logs: logs, limits: limits, redactor: newRedactor(secrets)}
+}
+
+// Write redacts all complete lines in p and writes them to the log stream.
+// ErrLogLimitExceeded is returned once the log size limit has been exceeded.
+func (w *LogWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.received += int64(len(p))
+	if w.limitHit {
+		return len(p), ErrLogLimitExceeded
+	}
+
+	w.pending = append(w.pending, p...)
+
+	var output bytes.Buffer
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+
+		if w.skipLine {
+			// the beginning of this line has already been written truncated
+			w.skipLine = false
+		} else {
+			output.WriteString(w.line(string(w.pending[:i])))
+			output.WriteByte('\n')
+		}
+		w.pending = w.pending[i+1:]
+	}
+
+	if w.limits.MaxLineSize > 0 && len(w.pending) > w.limits.MaxLineSize {
+		if w.skipLine {
+			w.pending = nil
+		} else if prefix, _ := w.redactor.RedactPrefix(string(w.pending), w.limits.MaxLineSize+1); len(prefix) > w.limits.MaxLineSize {
+			// secrets crossing the limit have been redacted completely before cutting the line,
+			// otherwise the line is kept until enough of it has been received
+			output.WriteString(truncate(prefix, w.limits.MaxLineSize) + LINE_TRUNCATED_MARKER)
+			output.WriteByte('\n')
+			w.skipLine = true
+			w.pending = nil
+		}
+	} else if w.limits.MaxLineSize <= 0 && len(w.pending) >= MAX_PENDING_LINE {
+		// the end of the fragment is kept, as it may be the beginning of a secret
+		prefix, n := w.redactor.RedactPrefix(string(w.pending), 0)
+		output.WriteString(prefix)
+		w.pending = w.pending[n:]
+	}
+	w.pending = append([]byte(nil), w.pending...)
+
+	return len(p), w.write(output.Bytes())
+}
+
+// line redacts and truncates a single line without line break.
+func (w *LogWriter) line(value string) string {
+	value = w.redactor.Redact(value)
+	if w.limits.MaxLineSize > 0 && len(value) > w.limits.MaxLineSize {
+		value = truncate(value, w.limits.MaxLineSize) + LINE_TRUNCATED_MARKER
+	}
+	return value
+}
+
+// write writes data to the log stream unless the log size limit would be exceeded.
+func (w *LogWriter) write(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	if w.limits.MaxSize > 0 && w.written+int64(len(data)) > w.limits.MaxSize {
+		w.limitHit = true
+		w.pending = nil
+
+		// complete lines are kept as long as they fit
+		if end := bytes.LastIndexByte(data[:w.limits.MaxSize-w.written], '\n') + 1; end > 0 {
+			w.logs.Write(data[:end])
+		}
+		io.WriteString(w.logs, LOG_TRUNCATED_MARKER)
+		return ErrLogLimitExceeded
+	}
+
+	n, err := w.logs.Write(data)
+	w.written += int64(n)
+	return err
+}
+
+// Received returns the number of bytes which have been uploaded, which is the position to continue an interrupted upload from.
+func (w *LogWriter) Received() int64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.received
+}
+
+// LimitExceeded returns true if remaining logs are being discarded.
+func (w *LogWriter) LimitExceeded() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.limitHit
+}
+
+// Flush writes the buffered unterminated line to the log stream.
+func (w *LogWriter) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.pending) == 0 || w.limitHit {
+		return nil
+	}
+
+	line := w.line(string(w.pending))
+	w.pending = nil
+	return w.write([]byte(line))
+}
+
+// OpenLogs creates the log stream of the activity, uploaded logs must be written using the returned writer.
+// The caller must hold the lock.
+func (r *RuntimeStatus) OpenLogs(limits LogLimits) *LogWriter {
+	logs := streams.NewStreamProvider(stream.NewMemStream())
+	r.Logs = logs
+	r.logWriter = newLogWriter(logs, r.secrets, limits)
+	return r.logWriter
+}
+
+// LogWriter returns the writer of the log stream or nil if the logs have not been opened yet.
+// The caller must hold the lock.
+func (r *RuntimeStatus) LogWriter() *LogWriter {
+	return r.logWriter
+}
+
+// closeLogs flushes and closes the log stream.
+// The caller must hold the lock.
+func (r *RuntimeStatus) closeLogs() {
+	if r.logWriter != nil {
+		r.logWriter.Flush()
+	}
+	if r.Logs != nil {
+		r.Logs.Close()
+	}
+}
@@ -0,0 +1,98 @@
+package activity
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/reeveci/reeve/reeve-common/mask"
+)
+
+// redactor replaces secret env values and their variants in logs.
+// The variants match the masking done by the runner, so that logs are redacted even if a worker does not mask them.
+type redactor struct {
+	replacer *strings.Replacer
+	// patterns contains the patterns starting with each byte in the order in which they are replaced.
+	patterns  map[byte][]string
+	maxLength int
+}
+
+// newRedactor returns a redactor for the given secrets and their variants, or nil if there are no secrets.
+func newRedactor(secrets []string) *redactor {
+	patterns := make(map[string]bool)
+	for _, secret := range secrets {
+		for _, pattern := range mask.Patterns(secret) {
+			// logs are redacted line by line, multi-line secrets have already been masked by the runner
+			if !strings.Contains(pattern, "\n") {
+				patterns[pattern] = true
+			}
+		}
+	}
+
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	r := &redactor{replacer: mask.NewReplacer(patterns), patterns: make(map[byte][]string)}
+	for _, pattern := range mask.Sorted(patterns) {
+		r.patterns[pattern[0]] = append(r.patterns[pattern[0]], pattern)
+		r.maxLength = max(r.maxLength, len(pattern))
+	}
+	return r
+}
+
+// Redact replaces all secrets in value.
+func (r *redactor) Redact(value string) string {
+	if r == nil {
+		return value
+	}
+	return r.replacer.Replace(value)
+}
+
+// RedactPrefix redacts value from its start until limit bytes have been written (0 means unlimited),
+// leaving the last bytes which may be the beginning of a secret completed by following data.
+// It returns the redacted prefix and the number of bytes of value it covers.
+func (r *redactor) RedactPrefix(value string, limit int) (string, int) {
+	if r == nil {
+		if limit > 0 && limit < len(value) {
+			return value[:limit], limit
+		}
+		return value, len(value)
+	}
+
+	// a pattern starting before end is contained in value completely
+	end := len(value) - (r.maxLength - 1)
+
+	var output strings.Builder
+	i := 0
+	for i < end && (limit <= 0 || output.Len() < limit) {
+		if n := r.matchAt(value, i); n > 0 {
+			output.WriteString(mask.MASK)
+			i += n
+		} else {
+			output.WriteByte(value[i])
+			i++
+		}
+	}
+	return output.String(), i
+}
+
+// matchAt returns the length of the pattern replaced at position i of value, or 0 if no pattern starts there.
+func (r *redactor) matchAt(value string, i int) int {
+	for _, pattern := range r.patterns[value[i]] {
+		if strings.HasPrefix(value[i:], pattern) {
+			return len(pattern)
+		}
+	}
+	return 0
+}
+
+// truncate cuts value to at most size bytes without splitting a rune.
+func truncate(value string, size int) string {
+	if len(value) <= size {
+		return value
+	}
+	for size > 0 && !utf8.RuneStart(value[size]) {
+		size--
+	}
+	return value[:size]
+}
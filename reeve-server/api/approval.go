@@ -0,0 +1,342 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-server/activity"
+	"github.com/reeveci/reeve/reeve-server/audit"
+	"github.com/reeveci/reeve/reeve-server/runtime"
+	"github.com/reeveci/reeve/reeve-server/triggers"
+)
+
+type WorkerApprovalRequest struct {
+	Step      int      `json:"step"`
+	Name      string   `json:"name"`
+	Message   string   `json:"message"`
+	Approvers []string `json:"approvers"`
+}
+
+type ApprovalDecisionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// HandleWorkerApproval pauses a pipeline for an approval step and blocks until the step has been decided.
+func HandleWorkerApproval(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if req.Header.Get("Content-type") != "application/json" {
+			http.Error(res, "Content-Type header is not application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		auditEntry := audit.FromContext(req.Context())
+
+		identity, ok := checkWorkerToken(req, runtime.WorkerSecrets)
+		if !ok {
+			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		auditEntry.Identity = identity
+
+		workerGroup, workerActivity, activityID, status, ok := findActivity(runtime, res, req)
+		if !ok {
+			return
+		}
+
+		var data WorkerApprovalRequest
+		decoder := json.NewDecoder(req.Body)
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&data)
+		if err != nil {
+			http.Error(res, fmt.Sprintf("invalid request body - %s", err), http.StatusBadRequest)
+			return
+		}
+
+		status.Lock()
+
+		if !status.Active() {
+			status.Unlock()
+			http.Error(res, fmt.Sprintf("invalid activity %s", activityID), http.StatusBadRequest)
+			return
+		}
+
+		decided, changed := status.RequestApproval(activity.Approval{
+			Step:      data.Step,
+			Name:      data.Name,
+			Message:   data.Message,
+			Approvers: data.Approvers,
+		})
+		if changed {
+			status.StartPhase("waiting approval")
+		}
+		status.Unlock()
+
+		if changed {
+			runtime.Log.Info(fmt.Sprintf("waiting for approval of step %s", data.Name), "workerGroup", workerGroup, "activity", activityID)
+			workerActivity.NotifyUpdate(activityID)
+		}
+
+		select {
+		case <-decided:
+		case <-req.Context().Done():
+		}
+
+		status.Lock()
+		decision := status.ReleaseApproval(workerActivity.Timeout, req.Context().Err() == nil)
+		if decision != nil {
+			status.StartPhase("execute")
+		}
+		status.Unlock()
+
+		if decision == nil {
+			http.Error(res, "approval is not pending anymore", http.StatusConflict)
+			return
+		}
+
+		workerActivity.NotifyUpdate(activityID)
+
+		res.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(res).Encode(decision)
+		if err != nil {
+			http.Error(res, fmt.Sprintf("error encoding response - %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// HandleApprovals lists the pending approvals the requesting token may decide.
+func HandleApprovals(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		identity, ok := checkApprovalToken(req, runtime.ApprovalSecrets)
+		if !ok {
+			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		audit.FromContext(req.Context()).Identity = identity
+
+		result := make([]activity.PendingApproval, 0)
+		for _, workerActivity := range runtime.Activity {
+			for _, approval := range workerActivity.PendingApprovals() {
+				if approval.MayDecide(identity) {
+					result = append(result, approval)
+				}
+			}
+		}
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Since.Before(result[j].Since)
+		})
+
+		res.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(res).Encode(result)
+		if err != nil {
+			http.Error(res, fmt.Sprintf("error encoding response - %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// HandleApprovalDecision approves or rejects a pending approval step.
+func HandleApprovalDecision(runtime *runtime.Runtime, approved bool) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		auditEntry := audit.FromContext(req.Context())
+
+		identity, ok := checkApprovalToken(req, runtime.ApprovalSecrets)
+		if !ok {
+			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		auditEntry.Identity = identity
+
+		_, _, activityID, status, ok := findActivity(runtime, res, req)
+		if !ok {
+			return
+		}
+
+		data, ok := readApprovalComment(res, req)
+		if !ok {
+			return
+		}
+
+		status.Lock()
+		defer status.Unlock()
+
+		if status.Approval == nil {
+			http.Error(res, fmt.Sprintf("activity %s is not waiting for approval", activityID), http.StatusBadRequest)
+			return
+		}
+		if !status.Approval.MayDecide(identity) {
+			http.Error(res, fmt.Sprintf("%s may not decide this approval", identity), http.StatusForbidden)
+			return
+		}
+
+		err := status.Decide(activity.ApprovalDecision{Approved: approved, By: identity, Comment: data.Comment})
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+}
+
+// HandleTriggerApprovals lists the parked triggers the requesting token may decide.
+func HandleTriggerApprovals(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		identity, ok := checkApprovalToken(req, runtime.ApprovalSecrets)
+		if !ok {
+			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		audit.FromContext(req.Context()).Identity = identity
+
+		result := runtime.ParkedTriggers.Decidable(identity)
+
+		res.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(res).Encode(result)
+		if err != nil {
+			http.Error(res, fmt.Sprintf("error encoding response - %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// HandleTriggerApprovalDecision approves or discards a parked trigger.
+// Approved triggers are processed again without applying trigger approval rules.
+func HandleTriggerApprovalDecision(runtime *runtime.Runtime, approved bool) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		auditEntry := audit.FromContext(req.Context())
+
+		identity, ok := checkApprovalToken(req, runtime.ApprovalSecrets)
+		if !ok {
+			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		auditEntry.Identity = identity
+
+		if approved && runtime.Draining() {
+			serviceUnavailable(res, DRAIN_RETRY_AFTER, "server is shutting down")
+			return
+		}
+
+		id := req.URL.Query().Get("id")
+		auditEntry.Activity = id
+		if id == "" {
+			http.Error(res, `missing required query parameter "id"`, http.StatusBadRequest)
+			return
+		}
+
+		data, ok := readApprovalComment(res, req)
+		if !ok {
+			return
+		}
+
+		parked := runtime.ParkedTriggers.Get(id)
+		if parked == nil {
+			http.Error(res, fmt.Sprintf("invalid trigger %s", id), http.StatusBadRequest)
+			return
+		}
+		if !parked.MayDecide(identity) {
+			http.Error(res, fmt.Sprintf("%s may not decide this trigger", identity), http.StatusForbidden)
+			return
+		}
+
+		if runtime.ParkedTriggers.Take(id) == nil {
+			http.Error(res, fmt.Sprintf("trigger %s has already been decided", id), http.StatusBadRequest)
+			return
+		}
+
+		comment := ""
+		if data.Comment != "" {
+			comment = fmt.Sprintf(" - %s", data.Comment)
+		}
+
+		if approved {
+			runtime.Log.Info(fmt.Sprintf("trigger %s has been approved by %s%s", id, identity, comment))
+			runtime.PushApprovedTrigger(req.Context(), parked.Trigger)
+		} else {
+			runtime.Log.Info(fmt.Sprintf("trigger %s has been discarded by %s%s", id, identity, comment))
+			triggers.Notify(runtime, parked, triggers.STATUS_DISCARDED)
+		}
+	}
+}
+
+// readApprovalComment reads the optional comment of a decision.
+func readApprovalComment(res http.ResponseWriter, req *http.Request) (data ApprovalDecisionRequest, ok bool) {
+	if req.ContentLength == 0 {
+		return data, true
+	}
+
+	if req.Header.Get("Content-type") != "application/json" {
+		http.Error(res, "Content-Type header is not application/json", http.StatusUnsupportedMediaType)
+		return data, false
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		http.Error(res, fmt.Sprintf("invalid request body - %s", err), http.StatusBadRequest)
+		return data, false
+	}
+
+	return data, true
+}
+
+// findActivity looks up the activity given by the group and activity query parameters.
+func findActivity(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Request) (workerGroup string, workerActivity *activity.RuntimeActivity, activityID string, status *activity.RuntimeStatus, ok bool) {
+	auditEntry := audit.FromContext(req.Context())
+
+	q := req.URL.Query()
+
+	workerGroup = q.Get("group")
+	if workerGroup == "" {
+		workerGroup = schema.DEFAULT_WORKER_GROUP
+	}
+	auditEntry.Target = workerGroup
+	workerActivity, ok = runtime.Activity[workerGroup]
+	if !ok {
+		http.Error(res, fmt.Sprintf("invalid worker group %s", workerGroup), http.StatusBadRequest)
+		return
+	}
+
+	activityID = q.Get("activity")
+	auditEntry.Activity = activityID
+	if activityID == "" {
+		http.Error(res, `missing required query parameter "activity"`, http.StatusBadRequest)
+		return workerGroup, workerActivity, activityID, nil, false
+	}
+
+	status = workerActivity.Status(activityID)
+	if status == nil {
+		http.Error(res, fmt.Sprintf("invalid activity %s", activityID), http.StatusBadRequest)
+		return workerGroup, workerActivity, activityID, nil, false
+	}
+
+	return workerGroup, workerActivity, activityID, status, true
+}
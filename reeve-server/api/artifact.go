@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-server/artifacts"
+	"github.com/reeveci/reeve/reeve-server/audit"
+	"github.com/reeveci/reeve/reeve-server/logging"
+	"github.com/reeveci/reeve/reeve-server/runtime"
+)
+
+func HandleWorkerArtifact(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		auditEntry := audit.FromContext(req.Context())
+
+		identity, ok := checkWorkerToken(req, runtime.WorkerSecrets)
+		if !ok {
+			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		auditEntry.Identity = identity
+
+		q := req.URL.Query()
+
+		workerGroup := q.Get("group")
+		if workerGroup == "" {
+			workerGroup = schema.DEFAULT_WORKER_GROUP
+		}
+		auditEntry.Target = workerGroup
+		workerActivity, ok := runtime.Activity[workerGroup]
+		if !ok {
+			http.Error(res, fmt.Sprintf("invalid worker group %s", workerGroup), http.StatusBadRequest)
+			return
+		}
+
+		activityID := q.Get("activity")
+		auditEntry.Activity = activityID
+		if activityID == "" {
+			http.Error(res, `missing required query parameter "activity"`, http.StatusBadRequest)
+			return
+		}
+
+		name := q.Get("name")
+		auditEntry.Method = name
+		if name == "" {
+			http.Error(res, `missing required query parameter "name"`, http.StatusBadRequest)
+			return
+		}
+		if !artifacts.ValidName(name) {
+			http.Error(res, fmt.Sprintf("invalid artifact name %s", name), http.StatusBadRequest)
+			return
+		}
+
+		status := workerActivity.Status(activityID)
+		if status == nil {
+			http.Error(res, fmt.Sprintf("invalid activity %s", activityID), http.StatusBadRequest)
+			return
+		}
+
+		status.Lock()
+		if !status.Active() {
+			status.Unlock()
+			http.Error(res, fmt.Sprintf("invalid activity %s", activityID), http.StatusBadRequest)
+			return
+		}
+		// uploads may take longer than the activity timeout
+		status.ClearTimeout()
+		pipeline := status.Pipeline.Name
+		status.Unlock()
+
+		artifact, err := runtime.Artifacts.Save(workerGroup, activityID, pipeline, name, req.Body)
+
+		status.Lock()
+		status.ResetTimeout(workerActivity.Timeout)
+		status.Unlock()
+
+		if err != nil {
+			if errors.Is(err, artifacts.ErrTooLarge) {
+				http.Error(res, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(res, fmt.Sprintf("error saving artifact - %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		runtime.Log.Info(fmt.Sprintf("artifact %s has been published", name), logging.KEY_WORKER_GROUP, workerGroup, logging.KEY_ACTIVITY, activityID, logging.KEY_PIPELINE, pipeline, "size", artifact.Size)
+
+		res.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(res).Encode(artifact)
+		if err != nil {
+			http.Error(res, fmt.Sprintf("error encoding response - %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func HandleArtifacts(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		auditEntry := audit.FromContext(req.Context())
+
+		identity, ok := checkCLIToken(req, runtime.CLISecrets)
+		if !ok {
+			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		auditEntry.Identity = identity
+
+		activityID := req.URL.Query().Get("activity")
+		auditEntry.Activity = activityID
+		if activityID == "" {
+			http.Error(res, `missing required query parameter "activity"`, http.StatusBadRequest)
+			return
+		}
+
+		result, err := runtime.Artifacts.List(activityID)
+		if err != nil {
+			if errors.Is(err, artifacts.ErrNotFound) {
+				http.Error(res, fmt.Sprintf("no artifacts for activity %s", activityID), http.StatusNotFound)
+				return
+			}
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(res).Encode(result)
+		if err != nil {
+			http.Error(res, fmt.Sprintf("error encoding response - %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func HandleArtifactDownload(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		auditEntry := audit.FromContext(req.Context())
+
+		identity, ok := checkCLIToken(req, runtime.CLISecrets)
+		if !ok {
+			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		auditEntry.Identity = identity
+
+		q := req.URL.Query()
+
+		activityID := q.Get("activity")
+		auditEntry.Activity = activityID
+		if activityID == "" {
+			http.Error(res, `missing required query parameter "activity"`, http.StatusBadRequest)
+			return
+		}
+
+		name := q.Get("name")
+		auditEntry.Method = name
+		if name == "" {
+			http.Error(res, `missing required query parameter "name"`, http.StatusBadRequest)
+			return
+		}
+
+		file, artifact, err := runtime.Artifacts.Open(activityID, name)
+		if err != nil {
+			if errors.Is(err, artifacts.ErrNotFound) {
+				http.Error(res, fmt.Sprintf("artifact %s not found", name), http.StatusNotFound)
+				return
+			}
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+
+		res.Header().Set("Content-Type", "application/octet-stream")
+		res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", artifact.Name))
+		http.ServeContent(res, req, artifact.Name, artifact.Created, file)
+	}
+}
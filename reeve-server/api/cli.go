@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/reeveci/reeve/reeve-server/audit"
 	"github.com/reeveci/reeve/reeve-server/runtime"
 )
 
@@ -25,10 +26,12 @@ func HandleCLI(runtime *runtime.Runtime) http.HandlerFunc {
 }
 
 func GetCLIUsage(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Request) {
-	if !checkCLIToken(req, runtime.CLISecrets) {
+	identity, ok := checkCLIToken(req, runtime.CLISecrets)
+	if !ok {
 		http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
+	audit.FromContext(req.Context()).Identity = identity
 
 	result := make(map[string]map[string]string, len(runtime.PluginProvider.CLIPlugins))
 
@@ -50,14 +53,19 @@ func SendCLIMethod(runtime *runtime.Runtime, res http.ResponseWriter, req *http.
 		return
 	}
 
-	if !checkCLIToken(req, runtime.CLISecrets) {
+	auditEntry := audit.FromContext(req.Context())
+
+	identity, ok := checkCLIToken(req, runtime.CLISecrets)
+	if !ok {
 		http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
+	auditEntry.Identity = identity
 
 	q := req.URL.Query()
 
 	target := q.Get("target")
+	auditEntry.Target = target
 	if target == "" {
 		http.Error(res, `missing required query parameter "target"`, http.StatusBadRequest)
 		return
@@ -69,6 +77,7 @@ func SendCLIMethod(runtime *runtime.Runtime, res http.ResponseWriter, req *http.
 	}
 
 	method := q.Get("method")
+	auditEntry.Method = method
 	if method == "" {
 		http.Error(res, `missing required query parameter "method"`, http.StatusBadRequest)
 		return
@@ -86,6 +95,7 @@ func SendCLIMethod(runtime *runtime.Runtime, res http.ResponseWriter, req *http.
 		http.Error(res, fmt.Sprintf("invalid request body - %s", err), http.StatusBadRequest)
 		return
 	}
+	auditEntry.Args = audit.RedactArgs(args, runtime.KnownSecrets())
 
 	result, err := plugin.CLIMethod(method, args)
 	if err != nil {
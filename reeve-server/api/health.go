@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/reeveci/reeve/reeve-common/healthcheck"
+	"github.com/reeveci/reeve/reeve-server/runtime"
+)
+
+// HandleHealth reports whether the server is alive, it fails if a queue loop is stuck or has exited.
+// Health endpoints are not authenticated and not audited, so that they can be polled by orchestrators.
+func HandleHealth(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		report := runtime.CheckHealth()
+		healthcheck.Write(res, report, report.Live)
+	}
+}
+
+// HandleReady reports whether the server accepts new work, it fails while loading plugins, draining, if the queues are full or a plugin stopped responding.
+func HandleReady(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		report := runtime.CheckReady()
+		healthcheck.Write(res, report, report.Ready)
+	}
+}
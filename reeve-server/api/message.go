@@ -1,11 +1,13 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-server/audit"
 	"github.com/reeveci/reeve/reeve-server/runtime"
 )
 
@@ -16,10 +18,19 @@ func HandleMessage(runtime *runtime.Runtime) http.HandlerFunc {
 			return
 		}
 
-		if !checkMessageToken(req, runtime.MessageSecrets) {
+		auditEntry := audit.FromContext(req.Context())
+
+		identity, ok := checkMessageToken(req, runtime.MessageSecrets)
+		if !ok {
 			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
 		}
+		auditEntry.Identity = identity
+
+		if runtime.Draining() {
+			serviceUnavailable(res, DRAIN_RETRY_AFTER, "server is shutting down")
+			return
+		}
 
 		var message schema.Message
 
@@ -37,6 +48,8 @@ func HandleMessage(runtime *runtime.Runtime) http.HandlerFunc {
 			}
 		}
 
+		auditEntry.Target = message.Target
+
 		switch message.Target {
 		case "":
 			http.Error(res, `missing required query parameter "target"`, http.StatusBadRequest)
@@ -51,13 +64,48 @@ func HandleMessage(runtime *runtime.Runtime) http.HandlerFunc {
 			}
 		}
 
+		if allowed, retryAfter := runtime.TokenLimiter.Allow(identity); !allowed {
+			tooManyRequests(res, retryAfter, "rate limit exceeded for token")
+			return
+		}
+		if allowed, retryAfter := runtime.TargetLimiter.Allow(message.Target); !allowed {
+			tooManyRequests(res, retryAfter, "rate limit exceeded for message target")
+			return
+		}
+		if targetFull(runtime, message.Target) {
+			tooManyRequests(res, QUEUE_RETRY_AFTER, "message queue for target is full")
+			return
+		}
+
 		var err error
-		message.Data, err = io.ReadAll(req.Body)
+		message.Data, err = io.ReadAll(http.MaxBytesReader(res, req.Body, runtime.MessageMaxSize))
 		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				http.Error(res, fmt.Sprintf("request body exceeds %v bytes", maxBytesError.Limit), http.StatusRequestEntityTooLarge)
+				return
+			}
 			http.Error(res, fmt.Sprintf("error reading request body - %s", err), http.StatusBadRequest)
 			return
 		}
 
-		runtime.MessageQueue.Push(schema.FullMessage{Message: message, Source: schema.MESSAGE_SOURCE_API})
+		if !runtime.TryPushMessage(req.Context(), schema.FullMessage{Message: message, Source: schema.MESSAGE_SOURCE_API}) {
+			tooManyRequests(res, QUEUE_RETRY_AFTER, "message queue is full")
+			return
+		}
 	}
 }
+
+func targetFull(runtime *runtime.Runtime, target string) bool {
+	if target == schema.BROADCAST_MESSAGE {
+		for _, queue := range runtime.MessageQueues {
+			if queue.Full() {
+				return true
+			}
+		}
+		return false
+	}
+
+	queue, ok := runtime.MessageQueues[target]
+	return ok && queue.Full()
+}
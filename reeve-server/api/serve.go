@@ -1,50 +1,132 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 
+	"github.com/reeveci/reeve/reeve-common/tracing"
+	"github.com/reeveci/reeve/reeve-server/logging"
 	"github.com/reeveci/reeve/reeve-server/runtime"
 )
 
-func ServeAPI(runtime *runtime.Runtime) {
+type Server struct {
+	runtime   *runtime.Runtime
+	mux       *http.ServeMux
+	listeners []listener
+}
+
+type listener struct {
+	*http.Server
+	tls bool
+}
+
+// NewServer creates a server which only answers health checks until EnableAPI is called,
+// so that it can be started before plugins have been loaded.
+func NewServer(runtime *runtime.Runtime) *Server {
+	mux := http.NewServeMux()
+
+	// Health API
+	mux.HandleFunc("/healthz", HandleHealth(runtime))
+	mux.HandleFunc("/readyz", HandleReady(runtime))
+
+	// API specification
+	mux.HandleFunc(runtime.PathPrefix+"/openapi.yaml", HandleOpenAPI())
+
+	// API requests before EnableAPI
+	mux.HandleFunc(runtime.PathPrefix+"/", func(res http.ResponseWriter, req *http.Request) {
+		if runtime.Health.Started() {
+			http.NotFound(res, req)
+			return
+		}
+		serviceUnavailable(res, STARTUP_RETRY_AFTER, "server is starting")
+	})
+
+	server := &Server{runtime: runtime, mux: mux}
+
+	if runtime.HTTPPort != "" {
+		server.listeners = append(server.listeners, listener{Server: &http.Server{Addr: fmt.Sprintf(":%s", runtime.HTTPPort), Handler: mux}})
+	}
+	if runtime.HTTPSPort != "" && runtime.TLSCert != "" && runtime.TLSKey != "" {
+		server.listeners = append(server.listeners, listener{Server: &http.Server{Addr: fmt.Sprintf(":%s", runtime.HTTPSPort), Handler: mux}, tls: true})
+	}
+
+	return server
+}
+
+// EnableAPI registers the functional endpoints, plugins and the audit log must have been set up.
+func (s *Server) EnableAPI() {
 	// start http service for message queue and worker queue
 	// check messageplugins before sending message into queue
 
+	mux := s.mux
+	runtime := s.runtime
+
 	// Message API
-	http.HandleFunc(runtime.PathPrefix+"/message", HandleMessage(runtime))
+	mux.HandleFunc(runtime.PathPrefix+"/message", tracing.Handler("HandleMessage", runtime.Audit.Handler("message", HandleMessage(runtime))))
 
 	// CLI API
-	http.HandleFunc(runtime.PathPrefix+"/cli", HandleCLI(runtime))
+	mux.HandleFunc(runtime.PathPrefix+"/cli", tracing.Handler("HandleCLI", runtime.Audit.Handler("cli", HandleCLI(runtime))))
+
+	// Trigger API
+	mux.HandleFunc(runtime.PathPrefix+"/trigger", tracing.Handler("HandleTrigger", runtime.Audit.Handler("cli", HandleTrigger(runtime))))
+	mux.HandleFunc(runtime.PathPrefix+"/trigger/explain", tracing.Handler("HandleTriggerExplain", runtime.Audit.Handler("cli", HandleTriggerExplain(runtime))))
 
 	// Worker API
-	http.HandleFunc(runtime.PathPrefix+"/worker/queue", HandleWorkerQueue(runtime))
-	http.HandleFunc(runtime.PathPrefix+"/worker/ack", HandleWorkerAck(runtime))
-	http.HandleFunc(runtime.PathPrefix+"/worker/logs", HandleWorkerLogs(runtime))
-	http.HandleFunc(runtime.PathPrefix+"/worker/result", HandleWorkerResult(runtime))
+	mux.HandleFunc(runtime.PathPrefix+"/worker/queue", tracing.Handler("HandleWorkerQueue", runtime.Audit.Handler("worker", HandleWorkerQueue(runtime))))
+	mux.HandleFunc(runtime.PathPrefix+"/worker/ack", tracing.Handler("HandleWorkerAck", runtime.Audit.Handler("worker", HandleWorkerAck(runtime))))
+	mux.HandleFunc(runtime.PathPrefix+"/worker/logs", tracing.Handler("HandleWorkerLogs", runtime.Audit.Handler("worker", HandleWorkerLogs(runtime))))
+	mux.HandleFunc(runtime.PathPrefix+"/worker/result", tracing.Handler("HandleWorkerResult", runtime.Audit.Handler("worker", HandleWorkerResult(runtime))))
+	mux.HandleFunc(runtime.PathPrefix+"/worker/approval", tracing.Handler("HandleWorkerApproval", runtime.Audit.Handler("worker", HandleWorkerApproval(runtime))))
+	mux.HandleFunc(runtime.PathPrefix+"/worker/artifact", tracing.Handler("HandleWorkerArtifact", runtime.Audit.Handler("worker", HandleWorkerArtifact(runtime))))
 
-	hasHTTP := runtime.HTTPPort != ""
-	hasHTTPS := runtime.HTTPSPort != "" && runtime.TLSCert != "" && runtime.TLSKey != ""
+	// Artifact API
+	mux.HandleFunc(runtime.PathPrefix+"/artifact", tracing.Handler("HandleArtifacts", runtime.Audit.Handler("cli", HandleArtifacts(runtime))))
+	mux.HandleFunc(runtime.PathPrefix+"/artifact/download", tracing.Handler("HandleArtifactDownload", runtime.Audit.Handler("cli", HandleArtifactDownload(runtime))))
 
-	if !hasHTTP {
-		ServeHTTPS(runtime)
-		return
-	}
+	// Approval API
+	mux.HandleFunc(runtime.PathPrefix+"/approval", tracing.Handler("HandleApprovals", runtime.Audit.Handler("approval", HandleApprovals(runtime))))
+	mux.HandleFunc(runtime.PathPrefix+"/approval/approve", tracing.Handler("HandleApprove", runtime.Audit.Handler("approval", HandleApprovalDecision(runtime, true))))
+	mux.HandleFunc(runtime.PathPrefix+"/approval/reject", tracing.Handler("HandleReject", runtime.Audit.Handler("approval", HandleApprovalDecision(runtime, false))))
+	mux.HandleFunc(runtime.PathPrefix+"/approval/trigger", tracing.Handler("HandleTriggerApprovals", runtime.Audit.Handler("approval", HandleTriggerApprovals(runtime))))
+	mux.HandleFunc(runtime.PathPrefix+"/approval/trigger/approve", tracing.Handler("HandleTriggerApprove", runtime.Audit.Handler("approval", HandleTriggerApprovalDecision(runtime, true))))
+	mux.HandleFunc(runtime.PathPrefix+"/approval/trigger/discard", tracing.Handler("HandleTriggerDiscard", runtime.Audit.Handler("approval", HandleTriggerApprovalDecision(runtime, false))))
+}
+
+// Serve blocks until all listeners have exited.
+func (s *Server) Serve() {
+	var wg sync.WaitGroup
+	wg.Add(len(s.listeners))
+
+	for _, l := range s.listeners {
+		go func() {
+			defer wg.Done()
 
-	if hasHTTPS {
-		go ServeHTTPS(runtime)
+			if l.tls {
+				s.runtime.ProcLog.Info(fmt.Sprintf("serving API at https://localhost:%s", s.runtime.HTTPSPort))
+				err := l.ListenAndServeTLS(s.runtime.TLSCert, s.runtime.TLSKey)
+				s.runtime.ProcLog.Info("HTTPS server exited", logging.Err(err))
+			} else {
+				s.runtime.ProcLog.Info(fmt.Sprintf("serving API at http://localhost:%s", s.runtime.HTTPPort))
+				err := l.ListenAndServe()
+				s.runtime.ProcLog.Info("HTTP server exited", logging.Err(err))
+			}
+		}()
 	}
-	ServeHTTP(runtime)
-}
 
-func ServeHTTPS(runtime *runtime.Runtime) {
-	runtime.ProcLog.Printf("serving API at https://localhost:%s\n", runtime.HTTPSPort)
-	err := http.ListenAndServeTLS(fmt.Sprintf(":%s", runtime.HTTPSPort), runtime.TLSCert, runtime.TLSKey, nil)
-	runtime.ProcLog.Printf("HTTPS server exited - %s\n", err)
+	wg.Wait()
 }
 
-func ServeHTTP(runtime *runtime.Runtime) {
-	runtime.ProcLog.Printf("serving API at http://localhost:%s\n", runtime.HTTPPort)
-	err := http.ListenAndServe(fmt.Sprintf(":%s", runtime.HTTPPort), nil)
-	runtime.ProcLog.Printf("HTTP server exited - %s\n", err)
+// Shutdown stops all listeners and waits for pending requests until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, l := range s.listeners {
+		err := l.Shutdown(ctx)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-server/audit"
+	"github.com/reeveci/reeve/reeve-server/runtime"
+	"github.com/reeveci/reeve/reeve-server/triggers"
+)
+
+func HandleTrigger(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if runtime.Draining() {
+			serviceUnavailable(res, DRAIN_RETRY_AFTER, "server is shutting down")
+			return
+		}
+
+		trigger, ok := readTrigger(runtime, res, req)
+		if !ok {
+			return
+		}
+
+		args := make([]string, 0, len(trigger))
+		for key, value := range trigger {
+			args = append(args, fmt.Sprintf("%s=%s", key, value))
+		}
+		sort.Strings(args)
+		audit.FromContext(req.Context()).Args = audit.RedactArgs(args, runtime.KnownSecrets())
+
+		if !runtime.TryPushTrigger(req.Context(), trigger) {
+			tooManyRequests(res, QUEUE_RETRY_AFTER, "trigger queue is full")
+			return
+		}
+	}
+}
+
+func HandleTriggerExplain(runtime *runtime.Runtime) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		trigger, ok := readTrigger(runtime, res, req)
+		if !ok {
+			return
+		}
+
+		explanation := triggers.Explain(req.Context(), runtime, trigger)
+
+		res.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(res).Encode(explanation)
+		if err != nil {
+			http.Error(res, fmt.Sprintf("error encoding explanation - %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// readTrigger authenticates a CLI request and decodes the trigger contained in its body.
+func readTrigger(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Request) (schema.Trigger, bool) {
+	if req.Header.Get("Content-type") != "application/json" {
+		http.Error(res, "Content-Type header is not application/json", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+
+	identity, ok := checkCLIToken(req, runtime.CLISecrets)
+	if !ok {
+		http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return nil, false
+	}
+	audit.FromContext(req.Context()).Identity = identity
+
+	var trigger schema.Trigger
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&trigger)
+	if err != nil {
+		http.Error(res, fmt.Sprintf("invalid request body - %s", err), http.StatusBadRequest)
+		return nil, false
+	}
+	if len(trigger) == 0 {
+		http.Error(res, "trigger is empty", http.StatusBadRequest)
+		return nil, false
+	}
+
+	return trigger, true
+}
@@ -1,13 +1,20 @@
 package api
 
 import (
+	"fmt"
+	"math"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const TOKEN_QUERY_PARAM = "token"
 
-func checkMessageToken(req *http.Request, secrets map[string]bool) bool {
+const QUEUE_RETRY_AFTER = 5 * time.Second
+const DRAIN_RETRY_AFTER = 30 * time.Second
+const STARTUP_RETRY_AFTER = 5 * time.Second
+
+func checkMessageToken(req *http.Request, secrets map[string]string) (string, bool) {
 	token := req.Header.Get("Authorization")
 
 	if token != "" {
@@ -18,24 +25,47 @@ func checkMessageToken(req *http.Request, secrets map[string]bool) bool {
 	}
 
 	token = strings.TrimSpace(token)
-	return len(token) > 0 && secrets[token]
+	return lookupToken(token, secrets)
+}
+
+func checkCLIToken(req *http.Request, secrets map[string]string) (string, bool) {
+	return checkBearerToken(req, secrets)
 }
 
-func checkCLIToken(req *http.Request, secrets map[string]bool) bool {
+func checkApprovalToken(req *http.Request, secrets map[string]string) (string, bool) {
 	return checkBearerToken(req, secrets)
 }
 
-func checkWorkerToken(req *http.Request, secrets map[string]bool) bool {
+func checkWorkerToken(req *http.Request, secrets map[string]string) (string, bool) {
 	return checkBearerToken(req, secrets)
 }
 
-func checkBearerToken(req *http.Request, secrets map[string]bool) bool {
+func checkBearerToken(req *http.Request, secrets map[string]string) (string, bool) {
 	token := req.Header.Get("Authorization")
 
 	if !strings.HasPrefix(token, "Bearer ") {
-		return false
+		return "", false
 	}
 
 	token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
-	return len(token) > 0 && secrets[token]
+	return lookupToken(token, secrets)
+}
+
+func lookupToken(token string, secrets map[string]string) (string, bool) {
+	if len(token) == 0 {
+		return "", false
+	}
+
+	name, ok := secrets[token]
+	return name, ok
+}
+
+func tooManyRequests(res http.ResponseWriter, retryAfter time.Duration, message string) {
+	res.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(res, message, http.StatusTooManyRequests)
+}
+
+func serviceUnavailable(res http.ResponseWriter, retryAfter time.Duration, message string) {
+	res.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(res, message, http.StatusServiceUnavailable)
 }
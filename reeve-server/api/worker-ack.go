@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-server/audit"
 	"github.com/reeveci/reeve/reeve-server/runtime"
 )
 
@@ -21,21 +22,31 @@ func HandleWorkerAck(runtime *runtime.Runtime) http.HandlerFunc {
 			return
 		}
 
-		if !checkWorkerToken(req, runtime.WorkerSecrets) {
+		auditEntry := audit.FromContext(req.Context())
+
+		identity, ok := checkWorkerToken(req, runtime.WorkerSecrets)
+		if !ok {
 			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
 		}
+		auditEntry.Identity = identity
 
 		workerGroup := req.URL.Query().Get("group")
 		if workerGroup == "" {
 			workerGroup = schema.DEFAULT_WORKER_GROUP
 		}
+		auditEntry.Target = workerGroup
 		queue, ok := runtime.WorkerQueues[workerGroup]
 		if !ok {
 			http.Error(res, fmt.Sprintf("invalid worker group %s", workerGroup), http.StatusBadRequest)
 			return
 		}
 
+		if runtime.Draining() {
+			serviceUnavailable(res, DRAIN_RETRY_AFTER, "server is shutting down")
+			return
+		}
+
 		queue.Contract.Consumer.Lock()
 
 		var data schema.WorkerAckRequest
@@ -48,6 +59,8 @@ func HandleWorkerAck(runtime *runtime.Runtime) http.HandlerFunc {
 			return
 		}
 
+		auditEntry.Contract = data.Contract
+
 		if data.Contract != queue.Contract.Contract || queue.Contract.IsCanceled() {
 			queue.Contract.Consumer.Unlock()
 			http.Error(res, fmt.Sprintf("invalid contract %s for worker group %s", data.Contract, workerGroup), http.StatusBadRequest)
@@ -56,6 +69,7 @@ func HandleWorkerAck(runtime *runtime.Runtime) http.HandlerFunc {
 
 		pipelineActivity := queue.Pop()
 		queue.Contract.Finish()
+		auditEntry.Activity = pipelineActivity.ActivityID
 
 		workerActivity := runtime.Activity[workerGroup]
 		status := workerActivity.Status(pipelineActivity.ActivityID)
@@ -67,6 +81,7 @@ func HandleWorkerAck(runtime *runtime.Runtime) http.HandlerFunc {
 		status.Lock()
 		status.Status = schema.STATUS_WAITING
 		status.ResetTimeout(workerActivity.Timeout)
+		status.StartPhase("execute")
 		status.Unlock()
 
 		workerActivity.NotifyUpdate(pipelineActivity.ActivityID)
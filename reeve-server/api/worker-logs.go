@@ -2,13 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 
-	"github.com/djherbis/stream"
 	"github.com/reeveci/reeve-lib/schema"
-	"github.com/reeveci/reeve-lib/streams"
+	"github.com/reeveci/reeve/reeve-server/activity"
+	"github.com/reeveci/reeve/reeve-server/audit"
+	"github.com/reeveci/reeve/reeve-server/logging"
 	"github.com/reeveci/reeve/reeve-server/runtime"
 )
 
@@ -29,10 +31,14 @@ func HandleWorkerLogs(runtime *runtime.Runtime) http.HandlerFunc {
 }
 
 func GetPosition(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Request) {
-	if !checkWorkerToken(req, runtime.WorkerSecrets) {
+	auditEntry := audit.FromContext(req.Context())
+
+	identity, ok := checkWorkerToken(req, runtime.WorkerSecrets)
+	if !ok {
 		http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
+	auditEntry.Identity = identity
 
 	q := req.URL.Query()
 
@@ -40,6 +46,7 @@ func GetPosition(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Re
 	if workerGroup == "" {
 		workerGroup = schema.DEFAULT_WORKER_GROUP
 	}
+	auditEntry.Target = workerGroup
 	workerActivity, ok := runtime.Activity[workerGroup]
 	if !ok {
 		http.Error(res, fmt.Sprintf("invalid worker group %s", workerGroup), http.StatusBadRequest)
@@ -47,6 +54,7 @@ func GetPosition(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Re
 	}
 
 	activityID := q.Get("activity")
+	auditEntry.Activity = activityID
 	if activityID == "" {
 		http.Error(res, `missing required query parameter "activity"`, http.StatusBadRequest)
 		return
@@ -66,15 +74,9 @@ func GetPosition(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Re
 	case schema.STATUS_WAITING:
 		response.Position = 0
 
-	case schema.STATUS_RUNNING:
-		reader, err := status.Logs.Reader()
-		if err != nil {
-			status.Unlock()
-			http.Error(res, fmt.Sprintf("unable to get stream reader - %s", err), http.StatusInternalServerError)
-			return
-		}
-		response.Position, _ = reader.Size()
-		reader.Close()
+	case schema.STATUS_RUNNING, activity.STATUS_WAITING_APPROVAL:
+		// the position refers to the uploaded logs, which differ from the stored logs after redaction
+		response.Position = status.LogWriter().Received()
 
 	default:
 		status.Unlock()
@@ -94,10 +96,14 @@ func GetPosition(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Re
 }
 
 func WriteLogs(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Request) {
-	if !checkWorkerToken(req, runtime.WorkerSecrets) {
+	auditEntry := audit.FromContext(req.Context())
+
+	identity, ok := checkWorkerToken(req, runtime.WorkerSecrets)
+	if !ok {
 		http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
+	auditEntry.Identity = identity
 
 	q := req.URL.Query()
 
@@ -105,6 +111,7 @@ func WriteLogs(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Requ
 	if workerGroup == "" {
 		workerGroup = schema.DEFAULT_WORKER_GROUP
 	}
+	auditEntry.Target = workerGroup
 	workerActivity, ok := runtime.Activity[workerGroup]
 	if !ok {
 		http.Error(res, fmt.Sprintf("invalid worker group %s", workerGroup), http.StatusBadRequest)
@@ -112,6 +119,7 @@ func WriteLogs(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Requ
 	}
 
 	activityID := q.Get("activity")
+	auditEntry.Activity = activityID
 	if activityID == "" {
 		http.Error(res, `missing required query parameter "activity"`, http.StatusBadRequest)
 		return
@@ -124,21 +132,20 @@ func WriteLogs(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Requ
 	}
 
 	status.Lock()
-	var logs *streams.StreamProvider
+	var logs *activity.LogWriter
 
 	switch status.Status {
 	case schema.STATUS_WAITING:
 		status.ClearTimeout()
-		logs = streams.NewStreamProvider(stream.NewMemStream())
-		status.Logs = logs
+		logs = status.OpenLogs(workerActivity.LogLimits)
 		status.Status = schema.STATUS_RUNNING
 		status.Unlock()
 
 		workerActivity.NotifyUpdate(activityID)
 
-	case schema.STATUS_RUNNING:
+	case schema.STATUS_RUNNING, activity.STATUS_WAITING_APPROVAL:
 		status.ClearTimeout()
-		logs = status.Logs.(*streams.StreamProvider)
+		logs = status.LogWriter()
 		status.Unlock()
 
 	default:
@@ -149,6 +156,32 @@ func WriteLogs(runtime *runtime.Runtime, res http.ResponseWriter, req *http.Requ
 
 	_, err := io.Copy(logs, req.Body)
 
+	if errors.Is(err, activity.ErrLogLimitExceeded) {
+		failed := false
+
+		status.Lock()
+		if workerActivity.LogLimits.Fail && status.Active() {
+			status.ClearTimeout()
+			status.Approval = nil
+			status.Result.Success = false
+			status.Result.Error = err.Error()
+			status.Status = schema.STATUS_FAILED
+			failed = true
+		} else {
+			status.ResetTimeout(workerActivity.Timeout)
+		}
+		status.Unlock()
+
+		if failed {
+			runtime.Log.Info("failing activity - log size limit exceeded", logging.KEY_WORKER_GROUP, workerGroup, logging.KEY_ACTIVITY, activityID)
+			workerActivity.NotifyUpdate(activityID)
+		}
+
+		// the worker stops uploading logs
+		http.Error(res, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
 	status.Lock()
 	status.ResetTimeout(workerActivity.Timeout)
 	status.Unlock()
@@ -6,6 +6,10 @@ import (
 	"net/http"
 
 	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-common/tracing"
+	"github.com/reeveci/reeve/reeve-server/activity"
+	"github.com/reeveci/reeve/reeve-server/audit"
+	"github.com/reeveci/reeve/reeve-server/logging"
 	"github.com/reeveci/reeve/reeve-server/runtime"
 )
 
@@ -16,24 +20,47 @@ func HandleWorkerQueue(runtime *runtime.Runtime) http.HandlerFunc {
 			return
 		}
 
-		if !checkWorkerToken(req, runtime.WorkerSecrets) {
+		auditEntry := audit.FromContext(req.Context())
+
+		identity, ok := checkWorkerToken(req, runtime.WorkerSecrets)
+		if !ok {
 			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
 		}
+		auditEntry.Identity = identity
 
 		workerGroup := req.URL.Query().Get("group")
 		if workerGroup == "" {
 			workerGroup = schema.DEFAULT_WORKER_GROUP
 		}
+		auditEntry.Target = workerGroup
 		queue, ok := runtime.WorkerQueues[workerGroup]
 		if !ok {
 			http.Error(res, fmt.Sprintf("invalid worker group %s", workerGroup), http.StatusBadRequest)
 			return
 		}
 
+		if runtime.Draining() {
+			serviceUnavailable(res, DRAIN_RETRY_AFTER, "server is shutting down")
+			return
+		}
+
+		ctx, cancel := runtime.DrainContext(req.Context())
+		defer cancel()
+
 		queue.Contract.Lock()
 
-		pipelineActivity := queue.Get()
+		ok = runtime.Activity[workerGroup].WaitForCapacity(ctx, queue.Concurrency)
+		var pipelineActivity activity.PipelineActivity
+		if ok {
+			pipelineActivity = queue.Get()
+		}
+
+		if runtime.Draining() {
+			queue.Contract.Unlock()
+			serviceUnavailable(res, DRAIN_RETRY_AFTER, "server is shutting down")
+			return
+		}
 
 		select {
 		case <-req.Context().Done():
@@ -43,10 +70,17 @@ func HandleWorkerQueue(runtime *runtime.Runtime) http.HandlerFunc {
 		default:
 		}
 
-		contract := queue.Contract.Next(runtime.QueueTimeout, func(contract string) {
-			runtime.Status <- []string{fmt.Sprintf("[%s|%s: %s] contract timed out", workerGroup, pipelineActivity.ActivityID, pipelineActivity.Name)}
+		workerActivity := runtime.Activity[workerGroup]
+		startPhase(workerActivity, pipelineActivity.ActivityID, "contract", nil, res.Header())
+
+		contract := queue.Contract.Next(queue.Timeout, func(contract string) {
+			runtime.StatusUpdate("contract timed out", logging.KEY_WORKER_GROUP, workerGroup, logging.KEY_ACTIVITY, pipelineActivity.ActivityID, logging.KEY_PIPELINE, pipelineActivity.Name)
+			startPhase(workerActivity, pipelineActivity.ActivityID, "queue wait", fmt.Errorf("contract timed out"), nil)
 		})
 
+		auditEntry.Activity = pipelineActivity.ActivityID
+		auditEntry.Contract = contract
+
 		res.Header().Set("Content-Type", "application/json")
 		err := json.NewEncoder(res).Encode(schema.WorkerQueueResponse{
 			Contract: contract,
@@ -55,8 +89,27 @@ func HandleWorkerQueue(runtime *runtime.Runtime) http.HandlerFunc {
 		})
 		if err != nil {
 			queue.Contract.Cancel()
+			startPhase(workerActivity, pipelineActivity.ActivityID, "queue wait", err, nil)
 			http.Error(res, fmt.Sprintf("error encoding pipeline - %s", err), http.StatusInternalServerError)
 			return
 		}
 	}
 }
+
+// startPhase ends the current trace phase of an activity, recording err, and starts the next phase.
+// If header is not nil, the trace context of the activity is written to it.
+func startPhase(workerActivity *activity.RuntimeActivity, activityID, phase string, err error, header http.Header) {
+	status := workerActivity.Status(activityID)
+	if status == nil {
+		return
+	}
+
+	status.Lock()
+	defer status.Unlock()
+
+	status.EndPhase(err)
+	status.StartPhase(phase)
+	if header != nil {
+		tracing.InjectFrom(status.TraceContext(), header)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-server/activity"
+	"github.com/reeveci/reeve/reeve-server/audit"
 	"github.com/reeveci/reeve/reeve-server/runtime"
 )
 
@@ -21,10 +23,14 @@ func HandleWorkerResult(runtime *runtime.Runtime) http.HandlerFunc {
 			return
 		}
 
-		if !checkWorkerToken(req, runtime.WorkerSecrets) {
+		auditEntry := audit.FromContext(req.Context())
+
+		identity, ok := checkWorkerToken(req, runtime.WorkerSecrets)
+		if !ok {
 			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
 		}
+		auditEntry.Identity = identity
 
 		q := req.URL.Query()
 
@@ -32,6 +38,7 @@ func HandleWorkerResult(runtime *runtime.Runtime) http.HandlerFunc {
 		if workerGroup == "" {
 			workerGroup = schema.DEFAULT_WORKER_GROUP
 		}
+		auditEntry.Target = workerGroup
 		workerActivity, ok := runtime.Activity[workerGroup]
 		if !ok {
 			http.Error(res, fmt.Sprintf("invalid worker group %s", workerGroup), http.StatusBadRequest)
@@ -39,6 +46,7 @@ func HandleWorkerResult(runtime *runtime.Runtime) http.HandlerFunc {
 		}
 
 		activityID := q.Get("activity")
+		auditEntry.Activity = activityID
 		if activityID == "" {
 			http.Error(res, `missing required query parameter "activity"`, http.StatusBadRequest)
 			return
@@ -52,7 +60,7 @@ func HandleWorkerResult(runtime *runtime.Runtime) http.HandlerFunc {
 
 		status.Lock()
 
-		if !status.Running() {
+		if !status.Active() {
 			status.Unlock()
 			http.Error(res, fmt.Sprintf("invalid activity %s", activityID), http.StatusBadRequest)
 			return
@@ -69,9 +77,12 @@ func HandleWorkerResult(runtime *runtime.Runtime) http.HandlerFunc {
 
 		status.ClearTimeout()
 
-		if status.Result.Success {
+		switch {
+		case status.Result.Success:
 			status.Status = schema.STATUS_SUCCESS
-		} else {
+		case status.Result.ExitCode == activity.EXIT_CODE_CANCELED:
+			status.Status = activity.STATUS_CANCELED
+		default:
 			status.Status = schema.STATUS_FAILED
 		}
 
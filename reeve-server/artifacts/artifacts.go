@@ -0,0 +1,260 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrTooLarge = errors.New("artifact exceeds the maximum size")
+var ErrNotFound = errors.New("artifact not found")
+
+var nameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// ValidName returns true if name may be used as artifact name or directory name of an activity.
+func ValidName(name string) bool {
+	return len(name) <= 128 && nameRegex.MatchString(name)
+}
+
+// Artifact is a file published by a pipeline, usually a gzipped tar archive created by reeve-tools.
+type Artifact struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+}
+
+// ActivityArtifacts contains all artifacts of an activity.
+type ActivityArtifacts struct {
+	WorkerGroup string     `json:"workerGroup"`
+	ActivityID  string     `json:"activity"`
+	Pipeline    string     `json:"pipeline"`
+	Artifacts   []Artifact `json:"artifacts"`
+}
+
+type activityInfo struct {
+	WorkerGroup string `json:"workerGroup"`
+	Pipeline    string `json:"pipeline"`
+}
+
+const infoFile = "activity.json"
+const artifactDirectory = "artifacts"
+
+// Store keeps artifacts on disk, grouped by activity.
+// Activities are removed once their newest artifact is older than the retention.
+type Store struct {
+	lock sync.Mutex
+
+	directory string
+	retention time.Duration
+	maxSize   int64
+}
+
+// NewStore creates a store in directory, which is created when the first artifact is saved.
+// A maxSize of 0 means unlimited, a retention of 0 keeps artifacts forever.
+func NewStore(directory string, retention time.Duration, maxSize int64) *Store {
+	return &Store{directory: directory, retention: retention, maxSize: maxSize}
+}
+
+func (s *Store) activityPath(activity string) string {
+	return filepath.Join(s.directory, activity)
+}
+
+// Save stores an artifact of an activity, replacing any existing artifact with the same name.
+func (s *Store) Save(workerGroup, activity, pipeline, name string, content io.Reader) (Artifact, error) {
+	if !ValidName(activity) {
+		return Artifact{}, fmt.Errorf("invalid activity %s", activity)
+	}
+	if !ValidName(name) {
+		return Artifact{}, fmt.Errorf("invalid artifact name %s", name)
+	}
+
+	dir := filepath.Join(s.activityPath(activity), artifactDirectory)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("error creating artifact directory - %s", err)
+	}
+
+	info, err := json.Marshal(activityInfo{WorkerGroup: workerGroup, Pipeline: pipeline})
+	if err != nil {
+		return Artifact{}, fmt.Errorf("error encoding activity info - %s", err)
+	}
+	err = os.WriteFile(filepath.Join(s.activityPath(activity), infoFile), info, 0600)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("error writing activity info - %s", err)
+	}
+
+	file, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return Artifact{}, fmt.Errorf("error creating artifact file - %s", err)
+	}
+	defer os.Remove(file.Name())
+
+	reader := content
+	if s.maxSize > 0 {
+		reader = io.LimitReader(content, s.maxSize+1)
+	}
+
+	size, err := io.Copy(file, reader)
+	closeErr := file.Close()
+	if err != nil {
+		return Artifact{}, fmt.Errorf("error writing artifact file - %s", err)
+	}
+	if closeErr != nil {
+		return Artifact{}, fmt.Errorf("error writing artifact file - %s", closeErr)
+	}
+	if s.maxSize > 0 && size > s.maxSize {
+		return Artifact{}, ErrTooLarge
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	err = os.Rename(file.Name(), filepath.Join(dir, name))
+	if err != nil {
+		return Artifact{}, fmt.Errorf("error writing artifact file - %s", err)
+	}
+
+	return Artifact{Name: name, Size: size, Created: time.Now()}, nil
+}
+
+// List returns all artifacts of an activity.
+func (s *Store) List(activity string) (*ActivityArtifacts, error) {
+	if !ValidName(activity) {
+		return nil, ErrNotFound
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(s.activityPath(activity), infoFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error reading activity info - %s", err)
+	}
+
+	var info activityInfo
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing activity info - %s", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.activityPath(activity), artifactDirectory))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading artifact directory - %s", err)
+	}
+
+	result := &ActivityArtifacts{
+		WorkerGroup: info.WorkerGroup,
+		ActivityID:  activity,
+		Pipeline:    info.Pipeline,
+		Artifacts:   make([]Artifact, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !ValidName(entry.Name()) {
+			continue
+		}
+
+		fileInfo, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		result.Artifacts = append(result.Artifacts, Artifact{Name: entry.Name(), Size: fileInfo.Size(), Created: fileInfo.ModTime()})
+	}
+
+	sort.Slice(result.Artifacts, func(i, j int) bool {
+		return result.Artifacts[i].Name < result.Artifacts[j].Name
+	})
+
+	return result, nil
+}
+
+// Open opens an artifact for reading, the caller must close the file.
+func (s *Store) Open(activity, name string) (*os.File, Artifact, error) {
+	if !ValidName(activity) || !ValidName(name) {
+		return nil, Artifact{}, ErrNotFound
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := os.Open(filepath.Join(s.activityPath(activity), artifactDirectory, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, Artifact{}, ErrNotFound
+		}
+		return nil, Artifact{}, fmt.Errorf("error opening artifact - %s", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, Artifact{}, fmt.Errorf("error opening artifact - %s", err)
+	}
+
+	return file, Artifact{Name: name, Size: info.Size(), Created: info.ModTime()}, nil
+}
+
+// Expire removes all activities whose newest artifact is older than the retention and returns the removed activity IDs.
+// Files which are being read are removed on Unix as soon as they are closed.
+func (s *Store) Expire() ([]string, error) {
+	if s.retention <= 0 {
+		return nil, nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entries, err := os.ReadDir(s.directory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading artifact directory - %s", err)
+	}
+
+	deadline := time.Now().Add(-s.retention)
+	var removed []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		if newest(filepath.Join(s.directory, entry.Name())).After(deadline) {
+			continue
+		}
+
+		err := os.RemoveAll(filepath.Join(s.directory, entry.Name()))
+		if err != nil {
+			return removed, fmt.Errorf("error removing artifacts of activity %s - %s", entry.Name(), err)
+		}
+		removed = append(removed, entry.Name())
+	}
+
+	return removed, nil
+}
+
+// newest returns the latest modification time of all files in dir.
+func newest(dir string) (result time.Time) {
+	filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().After(result) {
+			result = info.ModTime()
+		}
+		return nil
+	})
+	return
+}
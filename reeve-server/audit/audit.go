@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/reeveci/reeve/reeve-server/logging"
+)
+
+const OUTCOME_SUCCESS = "success"
+const OUTCOME_FAILURE = "failure"
+const OUTCOME_DENIED = "denied"
+
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Identity string    `json:"identity"`
+	API      string    `json:"api"`
+	Endpoint string    `json:"endpoint"`
+	Request  string    `json:"request"`
+	Remote   string    `json:"remote"`
+
+	Target   string   `json:"target,omitempty"`
+	Method   string   `json:"method,omitempty"`
+	Args     []string `json:"args,omitempty"`
+	Activity string   `json:"activity,omitempty"`
+	Contract string   `json:"contract,omitempty"`
+
+	Status  int    `json:"status"`
+	Outcome string `json:"outcome"`
+}
+
+type Sink interface {
+	Write(entry Entry) error
+	Close() error
+}
+
+type Auditor struct {
+	lock  sync.Mutex
+	sinks []Sink
+
+	ErrorLog *slog.Logger
+}
+
+func (a *Auditor) AddSink(sink Sink) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.sinks = append(a.sinks, sink)
+}
+
+func (a *Auditor) Enabled() bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return len(a.sinks) > 0
+}
+
+func (a *Auditor) Record(entry Entry) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, sink := range a.sinks {
+		err := sink.Write(entry)
+		if err != nil && a.ErrorLog != nil {
+			a.ErrorLog.Error("writing audit entry failed", logging.Err(err))
+		}
+	}
+}
+
+func (a *Auditor) Close() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, sink := range a.sinks {
+		sink.Close()
+	}
+	a.sinks = nil
+}
+
+type entryKey struct{}
+
+// Handler wraps an API handler and records one audit entry per request.
+// The handler may add details to the entry using FromContext.
+func (a *Auditor) Handler(api string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		entry := &Entry{
+			Time:     time.Now(),
+			API:      api,
+			Endpoint: req.URL.Path,
+			Request:  req.Method,
+			Remote:   req.RemoteAddr,
+		}
+
+		recorder := &statusRecorder{ResponseWriter: res, status: http.StatusOK}
+		handler(recorder, req.WithContext(context.WithValue(req.Context(), entryKey{}, entry)))
+
+		entry.Status = recorder.status
+		switch {
+		case recorder.status == http.StatusUnauthorized || recorder.status == http.StatusForbidden:
+			entry.Outcome = OUTCOME_DENIED
+		case recorder.status >= 400:
+			entry.Outcome = OUTCOME_FAILURE
+		default:
+			entry.Outcome = OUTCOME_SUCCESS
+		}
+
+		a.Record(*entry)
+	}
+}
+
+// FromContext returns the audit entry of the current request.
+// It always returns a valid entry, so handlers do not need to check whether auditing is enabled.
+func FromContext(ctx context.Context) *Entry {
+	if entry, ok := ctx.Value(entryKey{}).(*Entry); ok {
+		return entry
+	}
+	return &Entry{}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+const EVENT_AUDIT = "audit"
+
+// NewMessageSink creates a sink which forwards audit entries as messages to a message plugin.
+func NewMessageSink(target string, push func(schema.FullMessage) error) *MessageSink {
+	return &MessageSink{target: target, push: push}
+}
+
+type MessageSink struct {
+	target string
+	push   func(schema.FullMessage) error
+}
+
+func (s *MessageSink) Write(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding audit entry - %s", err)
+	}
+
+	return s.push(schema.FullMessage{
+		Message: schema.Message{
+			Target:  s.target,
+			Options: map[string]string{"event": EVENT_AUDIT},
+			Data:    data,
+		},
+		Source: schema.MESSAGE_SOURCE_SERVER,
+	})
+}
+
+func (s *MessageSink) Close() error {
+	return nil
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"regexp"
+	"strings"
+)
+
+const REDACTED = "*******"
+
+var secretNameRegex = regexp.MustCompile(`(?i)(secret|passw(or)?d|token|key|credential|auth)`)
+
+// RedactArgs censors CLI arguments which are likely to contain secrets.
+// This includes values of secret looking options ("--password value", "token=value")
+// as well as any occurrence of the given known secrets.
+func RedactArgs(args []string, secrets []string) []string {
+	result := make([]string, len(args))
+	redactNext := false
+
+	for i, arg := range args {
+		if redactNext {
+			redactNext = false
+			if !strings.HasPrefix(arg, "-") {
+				result[i] = REDACTED
+				continue
+			}
+		}
+
+		for _, secret := range secrets {
+			if secret != "" {
+				arg = strings.ReplaceAll(arg, secret, REDACTED)
+			}
+		}
+
+		if name, _, found := strings.Cut(arg, "="); found {
+			if secretNameRegex.MatchString(name) {
+				arg = name + "=" + REDACTED
+			}
+		} else if strings.HasPrefix(arg, "-") && secretNameRegex.MatchString(arg) {
+			redactNext = true
+		}
+
+		result[i] = arg
+	}
+
+	return result
+}
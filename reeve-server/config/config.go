@@ -0,0 +1,253 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-server/logging"
+	"gopkg.in/yaml.v3"
+)
+
+const DEFAULT_QUEUE_TIMEOUT = 1 * time.Minute
+const DEFAULT_ACTIVITY_TIMEOUT = 2 * time.Minute
+const DEFAULT_QUEUE_SIZE = 1000
+const DEFAULT_SHUTDOWN_TIMEOUT = 5 * time.Minute
+const DEFAULT_ARTIFACT_RETENTION = 7 * 24 * time.Hour
+
+const LOG_LIMIT_TRUNCATE = "truncate"
+const LOG_LIMIT_FAIL = "fail"
+
+// Config contains all server settings.
+// Settings are loaded from an optional YAML file and may be overridden using REEVE_* environment variables.
+type Config struct {
+	PluginDirectory string `yaml:"pluginDirectory"`
+	HTTPPort        string `yaml:"httpPort"`
+	HTTPSPort       string `yaml:"httpsPort"`
+	TLS             TLS    `yaml:"tls"`
+
+	// LogFormat is either text or json.
+	LogFormat string  `yaml:"logFormat"`
+	Tracing   Tracing `yaml:"tracing"`
+
+	Secrets      Secrets                `yaml:"secrets"`
+	WorkerGroups map[string]WorkerGroup `yaml:"workerGroups"`
+
+	// TriggerApproval contains rules for triggers whose pipelines must be approved before secrets are resolved and the pipelines are enqueued.
+	TriggerApproval []TriggerApprovalRule `yaml:"triggerApproval"`
+
+	Audit     Audit     `yaml:"audit"`
+	Limits    Limits    `yaml:"limits"`
+	Artifacts Artifacts `yaml:"artifacts"`
+
+	// ShutdownTimeout limits the time the server waits for running activities when shutting down.
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// StateFile is used to persist queued triggers, messages and pipelines across restarts.
+	StateFile string `yaml:"stateFile"`
+
+	Plugins map[string]map[string]string `yaml:"plugins"`
+	Shared  map[string]string            `yaml:"shared"`
+}
+
+type TLS struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+type Secrets struct {
+	Message []Token `yaml:"message"`
+	CLI     []Token `yaml:"cli"`
+	Worker  []Token `yaml:"worker"`
+	// Approval tokens may approve or reject pipelines waiting for approval.
+	Approval []Token `yaml:"approval"`
+}
+
+// Token is an API token with a name identifying the token holder.
+// In YAML, a token may either be a plain string or an object containing name and token.
+// Plain tokens are used as they are, unnamed tokens are identified by a short fingerprint.
+type Token struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+}
+
+func (t *Token) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = Token{Token: node.Value}
+		return nil
+	}
+
+	type plain Token
+	return node.Decode((*plain)(t))
+}
+
+type WorkerGroup struct {
+	// Timeout is the time after which an activity is considered dead if the worker does not report back.
+	Timeout time.Duration `yaml:"timeout"`
+	// QueueTimeout is the time a worker has to acknowledge a pipeline it received.
+	QueueTimeout time.Duration `yaml:"queueTimeout"`
+	// Concurrency limits the number of activities running at the same time, 0 means unlimited.
+	Concurrency int `yaml:"concurrency"`
+}
+
+// TriggerApprovalRule matches triggers using conditions on their values.
+// A rule matches if all conditions are satisfied, values missing in the trigger never match.
+type TriggerApprovalRule struct {
+	When map[string]schema.Condition `yaml:"when"`
+	// Approvers contains the names of the approval tokens which may decide, any approval token may decide if empty.
+	Approvers []string `yaml:"approvers"`
+}
+
+type Audit struct {
+	File       string `yaml:"file"`
+	MaxSize    int    `yaml:"maxSize"`
+	MaxBackups int    `yaml:"maxBackups"`
+	Target     string `yaml:"target"`
+}
+
+type Artifacts struct {
+	// Directory stores the artifacts published by pipelines.
+	Directory string `yaml:"directory"`
+	// Retention is the time artifacts are kept after the last artifact of an activity has been published, 0 keeps artifacts forever.
+	Retention time.Duration `yaml:"retention"`
+	// MaxSize limits the size of a single artifact in MB, 0 means unlimited.
+	MaxSize int `yaml:"maxSize"`
+}
+
+type Tracing struct {
+	// Exporter is one of none, otlp or file.
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP HTTP endpoint, OTEL_EXPORTER_OTLP_* environment variables apply if empty.
+	Endpoint string `yaml:"endpoint"`
+	// File receives spans as JSON lines when using the file exporter.
+	File string `yaml:"file"`
+}
+
+type Limits struct {
+	MessageTokenRate  string `yaml:"messageTokenRate"`
+	MessageTargetRate string `yaml:"messageTargetRate"`
+	MessageMaxSize    int    `yaml:"messageMaxSize"`
+	QueueSize         int    `yaml:"queueSize"`
+	// LogMaxSize limits the logs stored for each activity in MB, 0 means unlimited.
+	LogMaxSize int `yaml:"logMaxSize"`
+	// LogMaxLineSize limits the length of log lines in KB, longer lines are truncated, 0 means unlimited.
+	LogMaxLineSize int `yaml:"logMaxLineSize"`
+	// LogLimitPolicy is either truncate or fail, failing the activity when the log size limit is exceeded.
+	LogLimitPolicy string `yaml:"logLimitPolicy"`
+}
+
+func Default() *Config {
+	return &Config{
+		PluginDirectory: "./plugins",
+		LogFormat:       logging.FORMAT_TEXT,
+
+		WorkerGroups: make(map[string]WorkerGroup),
+
+		Audit: Audit{
+			MaxSize:    10,
+			MaxBackups: 5,
+		},
+
+		Limits: Limits{
+			MessageMaxSize: 10,
+			QueueSize:      DEFAULT_QUEUE_SIZE,
+			LogMaxSize:     100,
+			LogMaxLineSize: 64,
+			LogLimitPolicy: LOG_LIMIT_TRUNCATE,
+		},
+
+		Artifacts: Artifacts{
+			Directory: "./artifacts",
+			Retention: DEFAULT_ARTIFACT_RETENTION,
+			MaxSize:   100,
+		},
+
+		ShutdownTimeout: DEFAULT_SHUTDOWN_TIMEOUT,
+
+		Plugins: make(map[string]map[string]string),
+		Shared:  make(map[string]string),
+	}
+}
+
+// Load reads the configuration file at path on top of the default configuration.
+// If path is empty, only the defaults are returned.
+func Load(path string) (*Config, error) {
+	config := Default()
+
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s - %s", path, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(config)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error parsing config file %s - %s", path, err)
+	}
+
+	if config.WorkerGroups == nil {
+		config.WorkerGroups = make(map[string]WorkerGroup)
+	}
+	if config.Plugins == nil {
+		config.Plugins = make(map[string]map[string]string)
+	}
+	if config.Shared == nil {
+		config.Shared = make(map[string]string)
+	}
+
+	return config, nil
+}
+
+// Group returns the settings of a worker group with defaults applied.
+func (c *Config) Group(name string) WorkerGroup {
+	group := c.WorkerGroups[name]
+	if group.Timeout == 0 {
+		group.Timeout = DEFAULT_ACTIVITY_TIMEOUT
+	}
+	if group.QueueTimeout == 0 {
+		group.QueueTimeout = DEFAULT_QUEUE_TIMEOUT
+	}
+	return group
+}
+
+// ParseNamedToken parses a token in the form "name:token", the token itself may contain ":".
+func ParseNamedToken(value string) (Token, error) {
+	name, token, found := strings.Cut(value, ":")
+	if !found || name == "" || token == "" {
+		return Token{}, fmt.Errorf("invalid named token - must be in the form name:token")
+	}
+	return Token{Name: name, Token: token}, nil
+}
+
+func TokenFingerprint(token string) string {
+	return fmt.Sprintf("token-%x", sha256.Sum256([]byte(token)))[:14]
+}
+
+func TokenMap(tokens []Token) map[string]string {
+	result := make(map[string]string, len(tokens))
+	for _, token := range tokens {
+		name := token.Name
+		if name == "" {
+			name = TokenFingerprint(token.Token)
+		}
+		result[token.Token] = name
+	}
+	return result
+}
+
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.Join(errs...)
+}
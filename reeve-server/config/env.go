@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ENV_PREFIX = "REEVE_"
+const PLUGIN_SETTING_PREFIX = "REEVE_PLUGIN_"
+const SHARED_SETTING_PREFIX = "REEVE_SHARED_"
+
+// ApplyEnv overrides configuration values with all REEVE_* environment variables which are set.
+func (c *Config) ApplyEnv() error {
+	var errs []error
+
+	envString("REEVE_PLUGIN_DIRECTORY", &c.PluginDirectory)
+	envString("REEVE_LOG_FORMAT", &c.LogFormat)
+	envString("REEVE_TRACING_EXPORTER", &c.Tracing.Exporter)
+	envString("REEVE_TRACING_ENDPOINT", &c.Tracing.Endpoint)
+	envString("REEVE_TRACING_FILE", &c.Tracing.File)
+	envString("REEVE_HTTP_PORT", &c.HTTPPort)
+	envString("REEVE_HTTPS_PORT", &c.HTTPSPort)
+	envString("REEVE_TLS_CERT_FILE", &c.TLS.CertFile)
+	envString("REEVE_TLS_KEY_FILE", &c.TLS.KeyFile)
+
+	errs = appendErr(errs, envTokens("REEVE_MESSAGE_SECRETS", "REEVE_MESSAGE_NAMED_SECRETS", &c.Secrets.Message))
+	errs = appendErr(errs, envTokens("REEVE_CLI_SECRETS", "REEVE_CLI_NAMED_SECRETS", &c.Secrets.CLI))
+	errs = appendErr(errs, envTokens("REEVE_WORKER_SECRETS", "REEVE_WORKER_NAMED_SECRETS", &c.Secrets.Worker))
+	errs = appendErr(errs, envTokens("REEVE_APPROVAL_SECRETS", "REEVE_APPROVAL_NAMED_SECRETS", &c.Secrets.Approval))
+
+	for _, group := range strings.Fields(os.Getenv("REEVE_WORKER_GROUPS")) {
+		if _, ok := c.WorkerGroups[group]; !ok {
+			c.WorkerGroups[group] = WorkerGroup{}
+		}
+	}
+
+	envString("REEVE_AUDIT_FILE", &c.Audit.File)
+	errs = appendErr(errs, envInt("REEVE_AUDIT_MAX_SIZE", &c.Audit.MaxSize))
+	errs = appendErr(errs, envInt("REEVE_AUDIT_MAX_BACKUPS", &c.Audit.MaxBackups))
+	envString("REEVE_AUDIT_TARGET", &c.Audit.Target)
+
+	envString("REEVE_MESSAGE_TOKEN_RATE", &c.Limits.MessageTokenRate)
+	envString("REEVE_MESSAGE_TARGET_RATE", &c.Limits.MessageTargetRate)
+	errs = appendErr(errs, envInt("REEVE_MESSAGE_MAX_SIZE", &c.Limits.MessageMaxSize))
+	errs = appendErr(errs, envInt("REEVE_QUEUE_SIZE", &c.Limits.QueueSize))
+	errs = appendErr(errs, envInt("REEVE_LOG_MAX_SIZE", &c.Limits.LogMaxSize))
+	errs = appendErr(errs, envInt("REEVE_LOG_MAX_LINE_SIZE", &c.Limits.LogMaxLineSize))
+	envString("REEVE_LOG_LIMIT_POLICY", &c.Limits.LogLimitPolicy)
+
+	envString("REEVE_ARTIFACT_DIRECTORY", &c.Artifacts.Directory)
+	errs = appendErr(errs, envDuration("REEVE_ARTIFACT_RETENTION", &c.Artifacts.Retention))
+	errs = appendErr(errs, envInt("REEVE_ARTIFACT_MAX_SIZE", &c.Artifacts.MaxSize))
+
+	errs = appendErr(errs, envDuration("REEVE_SHUTDOWN_TIMEOUT", &c.ShutdownTimeout))
+	envString("REEVE_STATE_FILE", &c.StateFile)
+
+	return joinErrors(errs)
+}
+
+// PluginSettings returns the settings for a plugin.
+// Shared settings apply to all plugins, plugin specific settings take precedence.
+// In both cases, environment variables take precedence over the config file.
+func (c *Config) PluginSettings(name string) map[string]string {
+	settings := make(map[string]string)
+
+	for key, value := range c.Shared {
+		settings[strings.ToUpper(key)] = value
+	}
+	for _, env := range os.Environ() {
+		origKey, value, _ := strings.Cut(env, "=")
+		key := strings.ToUpper(origKey)
+		if strings.HasPrefix(key, SHARED_SETTING_PREFIX) && len(key) > len(SHARED_SETTING_PREFIX) {
+			settings[strings.TrimPrefix(key, SHARED_SETTING_PREFIX)] = value
+		}
+	}
+
+	for pluginName, pluginSettings := range c.Plugins {
+		if strings.EqualFold(pluginName, name) {
+			for key, value := range pluginSettings {
+				settings[strings.ToUpper(key)] = value
+			}
+		}
+	}
+	pluginPrefix := fmt.Sprintf("%s%s_", PLUGIN_SETTING_PREFIX, strings.ToUpper(name))
+	for _, env := range os.Environ() {
+		origKey, value, _ := strings.Cut(env, "=")
+		key := strings.ToUpper(origKey)
+		if strings.HasPrefix(key, pluginPrefix) && len(key) > len(pluginPrefix) {
+			settings[strings.TrimPrefix(key, pluginPrefix)] = value
+		}
+	}
+
+	return settings
+}
+
+func envString(name string, target *string) {
+	if value, ok := os.LookupEnv(name); ok {
+		*target = value
+	}
+}
+
+func envInt(name string, target *int) error {
+	value, ok := os.LookupEnv(name)
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	result, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("%s: invalid number %s", name, value)
+	}
+
+	*target = result
+	return nil
+}
+
+func envDuration(name string, target *time.Duration) error {
+	value, ok := os.LookupEnv(name)
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	result, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("%s: invalid duration %s", name, value)
+	}
+
+	*target = result
+	return nil
+}
+
+// envTokens reads plain tokens from name, which are used as they are, and tokens in the form name:token from namedName.
+// The configured tokens are replaced if any of both variables is set.
+func envTokens(name, namedName string, target *[]Token) error {
+	value, ok := os.LookupEnv(name)
+	namedValue, namedOk := os.LookupEnv(namedName)
+	if !ok && !namedOk {
+		return nil
+	}
+
+	var tokens []Token
+	for _, field := range strings.Fields(value) {
+		tokens = append(tokens, Token{Token: field})
+	}
+	for _, field := range strings.Fields(namedValue) {
+		token, err := ParseNamedToken(field)
+		if err != nil {
+			return fmt.Errorf("%s: %s", namedName, err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	*target = tokens
+	return nil
+}
+
+func appendErr(errs []error, err error) []error {
+	if err != nil {
+		return append(errs, err)
+	}
+	return errs
+}
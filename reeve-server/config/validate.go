@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-common/tracing"
+	"github.com/reeveci/reeve/reeve-server/logging"
+	"github.com/reeveci/reeve/reeve-server/ratelimit"
+)
+
+var tokenNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.@-]+$`)
+var pluginNameRegex = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+// Validate checks the configuration for errors, reporting all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if c.PluginDirectory == "" {
+		fail("pluginDirectory", "must not be empty")
+	} else if info, err := os.Stat(c.PluginDirectory); err != nil {
+		fail("pluginDirectory", "%s", err)
+	} else if !info.IsDir() {
+		fail("pluginDirectory", "%s is not a directory", c.PluginDirectory)
+	}
+
+	if !logging.ValidFormat(c.LogFormat) {
+		fail("logFormat", "invalid format %s, must be %s or %s", c.LogFormat, logging.FORMAT_TEXT, logging.FORMAT_JSON)
+	}
+
+	if !tracing.ValidExporter(c.Tracing.Exporter) {
+		fail("tracing.exporter", "invalid exporter %s, must be %s, %s or %s", c.Tracing.Exporter, tracing.EXPORTER_NONE, tracing.EXPORTER_OTLP, tracing.EXPORTER_FILE)
+	} else if c.Tracing.Exporter == tracing.EXPORTER_FILE && c.Tracing.File == "" {
+		fail("tracing.file", "must be specified when using the file exporter")
+	}
+
+	validatePort := func(field, port string) {
+		if port == "" {
+			return
+		}
+		if value, err := strconv.Atoi(port); err != nil || value < 1 || value > 65535 {
+			fail(field, "invalid port %s", port)
+		}
+	}
+	validatePort("httpPort", c.HTTPPort)
+	validatePort("httpsPort", c.HTTPSPort)
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		fail("tls", "certFile and keyFile must be specified together")
+	}
+	for field, file := range map[string]string{"tls.certFile": c.TLS.CertFile, "tls.keyFile": c.TLS.KeyFile} {
+		if file != "" {
+			if _, err := os.Stat(file); err != nil {
+				fail(field, "%s", err)
+			}
+		}
+	}
+
+	validateTokens := func(field string, tokens []Token) {
+		names := make(map[string]bool, len(tokens))
+		values := make(map[string]bool, len(tokens))
+		for i, token := range tokens {
+			tokenField := fmt.Sprintf("%s[%v]", field, i)
+			if strings.TrimSpace(token.Token) == "" {
+				fail(tokenField, "token must not be empty")
+			} else if strings.ContainsAny(token.Token, " \t\r\n") {
+				fail(tokenField, "token must not contain whitespace")
+			} else if values[token.Token] {
+				fail(tokenField, "duplicate token")
+			}
+			values[token.Token] = true
+
+			if token.Name != "" {
+				if !tokenNameRegex.MatchString(token.Name) {
+					fail(tokenField, "invalid name %s, only [a-zA-Z0-9_.@-] are allowed", token.Name)
+				} else if names[token.Name] {
+					fail(tokenField, "duplicate name %s", token.Name)
+				}
+				names[token.Name] = true
+			}
+		}
+	}
+	validateTokens("secrets.message", c.Secrets.Message)
+	validateTokens("secrets.cli", c.Secrets.CLI)
+	validateTokens("secrets.worker", c.Secrets.Worker)
+	validateTokens("secrets.approval", c.Secrets.Approval)
+
+	approvers := make(map[string]bool, len(c.Secrets.Approval))
+	for _, name := range TokenMap(c.Secrets.Approval) {
+		approvers[name] = true
+	}
+	for i, rule := range c.TriggerApproval {
+		field := fmt.Sprintf("triggerApproval[%v]", i)
+		if len(approvers) == 0 {
+			fail(field, "requires approval tokens (secrets.approval)")
+		}
+		if len(rule.When) == 0 {
+			fail(field+".when", "must not be empty")
+		}
+		for key, condition := range rule.When {
+			conditionField := fmt.Sprintf("%s.when.%s", field, key)
+			if key == "" || strings.HasPrefix(key, schema.ENV_PREFIX) || strings.HasPrefix(key, schema.VAR_PREFIX) ||
+				len(condition.IncludeEnv) > 0 || len(condition.ExcludeEnv) > 0 || len(condition.IncludeVar) > 0 || len(condition.ExcludeVar) > 0 {
+				fail(conditionField, "only trigger values may be checked, env and vars are resolved after approval")
+			}
+			for _, pattern := range append(append([]string{}, condition.Match...), condition.Mismatch...) {
+				if _, err := regexp.Compile(pattern); err != nil {
+					fail(conditionField, "invalid pattern %s - %s", pattern, err)
+				}
+			}
+		}
+		for _, name := range rule.Approvers {
+			if !approvers[name] {
+				fail(field+".approvers", "unknown approval token %s", name)
+			}
+		}
+	}
+
+	for name, group := range c.WorkerGroups {
+		field := fmt.Sprintf("workerGroups.%s", name)
+		if name == "" || strings.ContainsAny(name, " \t\r\n&?=/") {
+			fail(field, "invalid worker group name")
+		}
+		if group.Timeout < 0 {
+			fail(field+".timeout", "must not be negative")
+		}
+		if group.QueueTimeout < 0 {
+			fail(field+".queueTimeout", "must not be negative")
+		}
+		if group.Concurrency < 0 {
+			fail(field+".concurrency", "must not be negative")
+		}
+	}
+
+	if c.Audit.MaxSize < 0 {
+		fail("audit.maxSize", "must not be negative")
+	}
+	if c.Audit.MaxBackups < 0 {
+		fail("audit.maxBackups", "must not be negative")
+	}
+
+	if _, err := ratelimit.ParseRate(c.Limits.MessageTokenRate); err != nil {
+		fail("limits.messageTokenRate", "%s", err)
+	}
+	if _, err := ratelimit.ParseRate(c.Limits.MessageTargetRate); err != nil {
+		fail("limits.messageTargetRate", "%s", err)
+	}
+	if c.Limits.MessageMaxSize <= 0 {
+		fail("limits.messageMaxSize", "must be positive")
+	}
+	if c.Limits.QueueSize < 0 {
+		fail("limits.queueSize", "must not be negative")
+	}
+	if c.Limits.LogMaxSize < 0 {
+		fail("limits.logMaxSize", "must not be negative")
+	}
+	if c.Limits.LogMaxLineSize < 0 {
+		fail("limits.logMaxLineSize", "must not be negative")
+	}
+	switch c.Limits.LogLimitPolicy {
+	case LOG_LIMIT_TRUNCATE, LOG_LIMIT_FAIL:
+	default:
+		fail("limits.logLimitPolicy", "invalid policy %s, must be %s or %s", c.Limits.LogLimitPolicy, LOG_LIMIT_TRUNCATE, LOG_LIMIT_FAIL)
+	}
+
+	if c.Artifacts.Directory == "" {
+		fail("artifacts.directory", "must not be empty")
+	}
+	if c.Artifacts.Retention < 0 {
+		fail("artifacts.retention", "must not be negative")
+	}
+	if c.Artifacts.MaxSize < 0 {
+		fail("artifacts.maxSize", "must not be negative")
+	}
+
+	if c.ShutdownTimeout < 0 {
+		fail("shutdownTimeout", "must not be negative")
+	}
+
+	for name := range c.Plugins {
+		if !pluginNameRegex.MatchString(name) {
+			fail(fmt.Sprintf("plugins.%s", name), "invalid plugin name, only [a-zA-Z0-9] are allowed")
+		}
+	}
+
+	return joinErrors(errs)
+}
@@ -2,40 +2,76 @@ package main
 
 import (
 	"github.com/reeveci/reeve-lib/plugin"
-	"github.com/reeveci/reeve-lib/queue"
 	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-common/tracing"
+	"github.com/reeveci/reeve/reeve-server/logging"
 	"github.com/reeveci/reeve/reeve-server/runtime"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func HandleMessageQueues(runtime *runtime.Runtime) {
+	defer runtime.Loops.Done()
+
+	loop := runtime.Health.Loop("messages")
+	defer loop.Exit()
+
 	for name, queue := range runtime.MessageQueues {
-		go HandleMessageQueue(runtime, queue, runtime.PluginProvider.MessagePlugins[name])
+		runtime.Loops.Add(1)
+		go HandleMessageQueue(runtime, name, queue, runtime.PluginProvider.MessagePlugins[name])
 	}
 
-	for {
-		message := runtime.MessageQueue.Pop()
+	for !runtime.Draining() {
+		message, ok := runtime.MessageQueue.Next()
+		if !ok {
+			return
+		}
+		loop.Busy()
 
 		switch message.Target {
 		case schema.BROADCAST_MESSAGE:
-			for _, queue := range runtime.MessageQueues {
-				queue.Push(message)
+			for name, queue := range runtime.MessageQueues {
+				if !queue.TryPush(message) {
+					runtime.ErrorLog.Error("message queue is full - dropping message", logging.KEY_PLUGIN, name)
+				}
 			}
 
 		default:
 			if queue, ok := runtime.MessageQueues[message.Target]; ok {
-				queue.Push(message)
+				if !queue.TryPush(message) {
+					runtime.ErrorLog.Error("message queue is full - dropping message", logging.KEY_PLUGIN, message.Target)
+				}
 			}
 		}
+		loop.Idle()
 	}
 }
 
-func HandleMessageQueue(runtime *runtime.Runtime, queue queue.Queue[schema.FullMessage], plugin plugin.Plugin) {
-	for {
-		message := queue.Pop()
+func HandleMessageQueue(runtime *runtime.Runtime, name string, queue *runtime.BoundedQueue[runtime.QueuedMessage], plugin plugin.Plugin) {
+	defer runtime.Loops.Done()
+
+	api := runtime.PluginAPIs[name]
+
+	loop := runtime.Health.Loop("messages/" + name)
+	defer loop.Exit()
+
+	for !runtime.Draining() {
+		message, ok := queue.Next()
+		if !ok {
+			return
+		}
+		loop.Busy()
+
+		_, span := tracing.StartFrom(message.Trace, "plugin message", tracing.ATTR_PLUGIN.String(name), tracing.ATTR_TARGET.String(message.Target))
+		api.SetTrace(span.SpanContext())
 
 		err := plugin.Message(message.Source, message.Message)
+
+		api.SetTrace(trace.SpanContext{})
+		tracing.End(span, err)
+		loop.Idle()
+
 		if err != nil {
-			runtime.ErrorLog.Printf("sending message to plugin %s failed - %s\n", message.Target, err)
+			runtime.ErrorLog.Error("sending message failed", logging.KEY_PLUGIN, name, logging.Err(err))
 			continue
 		}
 	}
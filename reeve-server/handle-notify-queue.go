@@ -3,14 +3,18 @@ package main
 import (
 	"sync"
 
+	"github.com/reeveci/reeve/reeve-server/logging"
 	"github.com/reeveci/reeve/reeve-server/runtime"
 )
 
 func HandleNotifyQueue(runtime *runtime.Runtime) {
 	pluginCount := len(runtime.PluginProvider.NotifyPlugins)
 
+	loop := runtime.Health.Loop("notify")
+
 	for {
 		notification := runtime.NotifyQueue.Pop()
+		loop.Busy()
 
 		if pluginCount > 0 {
 			var wg sync.WaitGroup
@@ -25,7 +29,7 @@ func HandleNotifyQueue(runtime *runtime.Runtime) {
 
 					err := plugin.Notify(notification)
 					if err != nil {
-						runtime.ErrorLog.Printf("sending notification to plugin %s failed - %s\n", pluginName, err)
+						runtime.ErrorLog.Error("sending notification failed", append(logging.StatusAttrs(notification), logging.KEY_PLUGIN, pluginName, logging.Err(err))...)
 						return
 					}
 				}()
@@ -33,5 +37,7 @@ func HandleNotifyQueue(runtime *runtime.Runtime) {
 
 			wg.Wait()
 		}
+
+		loop.Idle()
 	}
 }
@@ -0,0 +1,233 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+const (
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
+)
+
+// Attribute keys used throughout the server.
+const (
+	KEY_KIND         = "kind"
+	KEY_WORKER_GROUP = "workerGroup"
+	KEY_ACTIVITY     = "activity"
+	KEY_PIPELINE     = "pipeline"
+	KEY_PLUGIN       = "plugin"
+	KEY_ERROR        = "error"
+)
+
+// Kinds of log records, each corresponding to one of the legacy line prefixes.
+const (
+	KIND_STATUS   = "status"
+	KIND_PROCESS  = "process"
+	KIND_PIPELINE = "pipeline"
+)
+
+var kindPrefix = map[string]string{
+	KIND_STATUS:   "*** ",
+	KIND_PROCESS:  "=== ",
+	KIND_PIPELINE: "### ",
+}
+
+const ERROR_PREFIX = "!!! "
+
+func ValidFormat(format string) bool {
+	return format == FORMAT_TEXT || format == FORMAT_JSON
+}
+
+// NewHandler creates a handler writing records of level warning and above to stderr and all other records to stdout.
+func NewHandler(format string, stdout, stderr io.Writer) slog.Handler {
+	switch format {
+	case FORMAT_JSON:
+		return &splitHandler{
+			out: slog.NewJSONHandler(stdout, nil),
+			err: slog.NewJSONHandler(stderr, nil),
+		}
+
+	default:
+		lock := &sync.Mutex{}
+		return &splitHandler{
+			out: &textHandler{lock: lock, w: stdout},
+			err: &textHandler{lock: lock, w: stderr},
+		}
+	}
+}
+
+// StatusAttrs returns the attributes identifying a pipeline activity.
+func StatusAttrs(status schema.PipelineStatus) []any {
+	return []any{
+		KEY_WORKER_GROUP, status.WorkerGroup,
+		KEY_ACTIVITY, status.ActivityID,
+		KEY_PIPELINE, status.Pipeline.Name,
+	}
+}
+
+// Err returns an attribute for err.
+func Err(err error) slog.Attr {
+	return slog.Any(KEY_ERROR, err)
+}
+
+type splitHandler struct {
+	out, err slog.Handler
+}
+
+func (h *splitHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level >= slog.LevelWarn {
+		return h.err.Enabled(ctx, level)
+	}
+	return h.out.Enabled(ctx, level)
+}
+
+func (h *splitHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn {
+		return h.err.Handle(ctx, record)
+	}
+	return h.out.Handle(ctx, record)
+}
+
+func (h *splitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &splitHandler{out: h.out.WithAttrs(attrs), err: h.err.WithAttrs(attrs)}
+}
+
+func (h *splitHandler) WithGroup(name string) slog.Handler {
+	return &splitHandler{out: h.out.WithGroup(name), err: h.err.WithGroup(name)}
+}
+
+// textHandler reproduces the human readable output of earlier versions:
+//
+//	*** [group|activity: pipeline] message key=value - error
+type textHandler struct {
+	lock  *sync.Mutex
+	w     io.Writer
+	attrs []slog.Attr
+	group string
+}
+
+func (h *textHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	result := *h
+	result.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	result.attrs = append(result.attrs, h.attrs...)
+	for _, attr := range attrs {
+		result.attrs = append(result.attrs, h.qualify(attr))
+	}
+	return &result
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	result := *h
+	result.group = h.group + name + "."
+	return &result
+}
+
+func (h *textHandler) qualify(attr slog.Attr) slog.Attr {
+	if h.group != "" {
+		attr.Key = h.group + attr.Key
+	}
+	return attr
+}
+
+func (h *textHandler) Handle(ctx context.Context, record slog.Record) error {
+	var kind, workerGroup, activity, pipeline, plugin string
+	var err any
+	var extra []string
+
+	var collect func(attr slog.Attr, prefix string)
+	collect = func(attr slog.Attr, prefix string) {
+		attr.Value = attr.Value.Resolve()
+		if attr.Equal(slog.Attr{}) {
+			return
+		}
+
+		if attr.Value.Kind() == slog.KindGroup {
+			for _, child := range attr.Value.Group() {
+				collect(child, prefix+attr.Key+".")
+			}
+			return
+		}
+
+		switch prefix + attr.Key {
+		case KEY_KIND:
+			kind = attr.Value.String()
+		case KEY_WORKER_GROUP:
+			workerGroup = attr.Value.String()
+		case KEY_ACTIVITY:
+			activity = attr.Value.String()
+		case KEY_PIPELINE:
+			pipeline = attr.Value.String()
+		case KEY_PLUGIN:
+			plugin = attr.Value.String()
+		case KEY_ERROR:
+			err = attr.Value.Any()
+		default:
+			extra = append(extra, fmt.Sprintf("%s%s=%v", prefix, attr.Key, attr.Value))
+		}
+	}
+
+	for _, attr := range h.attrs {
+		collect(attr, "")
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		collect(h.qualify(attr), "")
+		return true
+	})
+
+	var line strings.Builder
+
+	if record.Level >= slog.LevelWarn {
+		line.WriteString(ERROR_PREFIX)
+	} else {
+		line.WriteString(kindPrefix[kind])
+	}
+
+	if workerGroup != "" || activity != "" {
+		line.WriteString("[" + workerGroup + "|" + activity)
+		if pipeline != "" {
+			line.WriteString(": " + pipeline)
+		}
+		line.WriteString("] ")
+	} else if pipeline != "" {
+		line.WriteString("[" + pipeline + "] ")
+	}
+	if kind == KIND_PIPELINE {
+		line.WriteString("> ")
+	}
+
+	if plugin != "" {
+		line.WriteString("<" + plugin + "> ")
+	}
+
+	line.WriteString(record.Message)
+
+	for _, value := range extra {
+		line.WriteString(" " + value)
+	}
+
+	if err != nil {
+		line.WriteString(fmt.Sprintf(" - %v", err))
+	}
+
+	line.WriteString("\n")
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	_, writeErr := io.WriteString(h.w, line.String())
+	return writeErr
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -9,8 +10,12 @@ import (
 	"path"
 	"syscall"
 
+	"github.com/reeveci/reeve-lib/exe"
 	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-common/tracing"
 	"github.com/reeveci/reeve/reeve-server/api"
+	"github.com/reeveci/reeve/reeve-server/config"
+	"github.com/reeveci/reeve/reeve-server/logging"
 	"github.com/reeveci/reeve/reeve-server/runtime"
 )
 
@@ -18,6 +23,7 @@ var buildVersion = "development"
 
 func main() {
 	var version bool
+	var configFile string
 
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options...]\n\n", os.Args[0])
@@ -26,6 +32,7 @@ func main() {
 
 	flag.BoolVar(&version, "version", false, "print build information and exit")
 	flag.BoolVar(&version, "v", false, "print build information and exit (shorthand)")
+	flag.StringVar(&configFile, "config", exe.GetEnvDef("REEVE_CONFIG_FILE", ""), "path to a YAML config file, environment variables take precedence (REEVE_CONFIG_FILE)")
 
 	flag.Parse()
 
@@ -34,47 +41,117 @@ func main() {
 		return
 	}
 
-	fmt.Printf("welcome to reeve server version %s\n", buildVersion)
-
 	procErrLog := log.New(os.Stderr, "", 0)
 
-	runtime := runtime.GetRuntime()
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		procErrLog.Fatalln(err)
+		return
+	}
+	err = cfg.ApplyEnv()
+	if err != nil {
+		procErrLog.Fatalf("invalid environment:\n%s", err)
+		return
+	}
+	err = cfg.Validate()
+	if err != nil {
+		procErrLog.Fatalf("invalid configuration:\n%s", err)
+		return
+	}
+
+	runtime := runtime.GetRuntime(cfg)
+	runtime.SetupLogging(logging.NewHandler(cfg.LogFormat, os.Stdout, os.Stderr))
 
-	runtime.Log = log.New(os.Stdout, "*** ", 0)
-	runtime.ProcLog = log.New(os.Stdout, "=== ", 0)
-	runtime.ErrorLog = log.New(os.Stderr, "!!! ", 0)
+	fatal := func(msg string, args ...any) {
+		runtime.ErrorLog.Error(msg, args...)
+		os.Exit(1)
+	}
+
+	runtime.ProcLog.Info(fmt.Sprintf("welcome to reeve server version %s", buildVersion))
+
+	shutdownTracing, err := tracing.Setup("reeve-server", cfg.Tracing.Exporter, cfg.Tracing.Endpoint, cfg.Tracing.File, buildVersion)
+	if err != nil {
+		fatal("error setting up tracing", logging.Err(err))
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), SERVER_SHUTDOWN_TIMEOUT)
+		defer cancel()
+
+		err := shutdownTracing(ctx)
+		if err != nil {
+			runtime.ErrorLog.Error("error flushing traces", logging.Err(err))
+		}
+	}()
 
 	go runtime.LogStatus()
+	go runtime.ExpireArtifacts()
+
+	// serve health checks while starting up
+	server := api.NewServer(runtime)
+	serverExited := make(chan struct{})
+	go func() {
+		server.Serve()
+		close(serverExited)
+	}()
 
-	err := runtime.LoadPlugins()
+	err = runtime.LoadPlugins()
 	if err != nil {
-		procErrLog.Fatalf("error loading plugins - %s", err)
+		fatal("error loading plugins", logging.Err(err))
 		return
 	}
 	defer runtime.PluginProvider.Close()
 
 	if len(runtime.PluginProvider.DiscoverPlugins) == 0 {
-		procErrLog.Fatalf("no discover plugins loaded")
+		fatal("no discover plugins loaded")
 		return
 	}
 
+	err = runtime.SetupAudit()
+	if err != nil {
+		fatal("error setting up audit log", logging.Err(err))
+		return
+	}
+	defer runtime.Audit.Close()
+
+	if runtime.Config.StateFile != "" {
+		err = restoreState(runtime, runtime.Config.StateFile)
+		if err != nil {
+			runtime.ErrorLog.Error("error restoring state", logging.Err(err))
+		}
+	}
+
 	signals := make(chan os.Signal, 2)
 	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		sig := <-signals
-		procErrLog.Printf("received %s signal\n", sig)
-		runtime.PluginProvider.Close()
-		os.Exit(1)
-	}()
 
+	runtime.Loops.Add(2)
 	go HandleMessageQueues(runtime)
 	go HandleTriggerQueue(runtime)
 	go HandleNotifyQueue(runtime)
 
-	runtime.MessageQueue.Push(schema.FullMessage{
+	runtime.PushMessage(context.Background(), schema.FullMessage{
 		Message: schema.BroadcastMessage(map[string]string{"event": schema.EVENT_STARTUP_COMPLETE}, nil),
 		Source:  schema.MESSAGE_SOURCE_SERVER,
 	})
 
-	api.ServeAPI(runtime)
+	server.EnableAPI()
+	runtime.Health.SetStarted()
+
+	select {
+	case <-serverExited:
+		return
+
+	case sig := <-signals:
+		runtime.ProcLog.Info(fmt.Sprintf("received %s signal - draining, send again to exit immediately", sig))
+	}
+
+	go func() {
+		sig := <-signals
+		runtime.ProcLog.Info(fmt.Sprintf("received %s signal - exiting", sig))
+		runtime.Audit.Close()
+		runtime.PluginProvider.Close()
+		os.Exit(1)
+	}()
+
+	shutdown(runtime, server)
 }
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Rate struct {
+	Count  int
+	Period time.Duration
+}
+
+func (r Rate) Enabled() bool {
+	return r.Count > 0 && r.Period > 0
+}
+
+func (r Rate) String() string {
+	if !r.Enabled() {
+		return ""
+	}
+	return fmt.Sprintf("%v/%s", r.Count, r.Period)
+}
+
+// ParseRate parses a rate such as "60/m", "5/s" or "100/1h".
+// An empty string disables rate limiting.
+func ParseRate(value string) (rate Rate, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	count, period, found := strings.Cut(value, "/")
+	if !found {
+		return rate, fmt.Errorf(`invalid rate "%s" - expected <count>/<period>`, value)
+	}
+
+	rate.Count, err = strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || rate.Count <= 0 {
+		return Rate{}, fmt.Errorf(`invalid rate "%s" - count must be a positive number`, value)
+	}
+
+	switch period = strings.TrimSpace(period); period {
+	case "s":
+		rate.Period = time.Second
+	case "m":
+		rate.Period = time.Minute
+	case "h":
+		rate.Period = time.Hour
+	default:
+		rate.Period, err = time.ParseDuration(period)
+		if err != nil || rate.Period <= 0 {
+			return Rate{}, fmt.Errorf(`invalid rate "%s" - invalid period %s`, value, period)
+		}
+	}
+
+	return
+}
+
+// NewLimiter creates a token bucket rate limiter, which tracks a separate bucket for each key.
+// Each bucket allows bursts of up to rate.Count requests.
+func NewLimiter(rate Rate) *Limiter {
+	return &Limiter{rate: rate, buckets: make(map[string]*bucket)}
+}
+
+type Limiter struct {
+	lock    sync.Mutex
+	rate    Rate
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Allow consumes one request for key.
+// If the request is not allowed, the returned duration states when the next request will be allowed.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil || !l.rate.Enabled() {
+		return true, 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	capacity := float64(l.rate.Count)
+	perToken := l.rate.Period / time.Duration(l.rate.Count)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		l.sweep(now)
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(perToken))
+	}
+
+	b.tokens -= 1
+	return true, 0
+}
+
+// sweep removes buckets which have been refilled completely, as they are equivalent to new buckets.
+func (l *Limiter) sweep(now time.Time) {
+	if len(l.buckets) < 1024 {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.rate.Period {
+			delete(l.buckets, key)
+		}
+	}
+}
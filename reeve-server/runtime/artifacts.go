@@ -0,0 +1,24 @@
+package runtime
+
+import (
+	"time"
+
+	"github.com/reeveci/reeve/reeve-server/logging"
+)
+
+const ARTIFACT_EXPIRY_INTERVAL = time.Hour
+
+// ExpireArtifacts periodically removes artifacts which exceeded their retention.
+func (runtime *Runtime) ExpireArtifacts() {
+	for {
+		removed, err := runtime.Artifacts.Expire()
+		if err != nil {
+			runtime.ErrorLog.Error("error removing expired artifacts", logging.Err(err))
+		}
+		if len(removed) > 0 {
+			runtime.ProcLog.Info("removed expired artifacts", "activities", len(removed))
+		}
+
+		time.Sleep(ARTIFACT_EXPIRY_INTERVAL)
+	}
+}
@@ -0,0 +1,47 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-server/audit"
+)
+
+func (runtime *Runtime) SetupAudit() error {
+	runtime.Audit.ErrorLog = runtime.ErrorLog
+
+	if runtime.AuditFile != "" {
+		sink, err := audit.NewFileSink(runtime.AuditFile, runtime.AuditMaxSize, runtime.AuditMaxBackups)
+		if err != nil {
+			return err
+		}
+		runtime.Audit.AddSink(sink)
+	}
+
+	if runtime.AuditTarget != "" {
+		if _, ok := runtime.PluginProvider.MessagePlugins[runtime.AuditTarget]; !ok {
+			runtime.Audit.Close()
+			return fmt.Errorf("audit target %s is not an available message plugin", runtime.AuditTarget)
+		}
+		runtime.Audit.AddSink(audit.NewMessageSink(runtime.AuditTarget, func(message schema.FullMessage) error {
+			if !runtime.TryPushMessage(context.Background(), message) {
+				return fmt.Errorf("message queue is full")
+			}
+			return nil
+		}))
+	}
+
+	return nil
+}
+
+// KnownSecrets returns all API tokens, which must never show up in audit logs.
+func (runtime *Runtime) KnownSecrets() []string {
+	result := make([]string, 0, len(runtime.MessageSecrets)+len(runtime.CLISecrets)+len(runtime.WorkerSecrets)+len(runtime.ApprovalSecrets))
+	for _, secrets := range []map[string]string{runtime.MessageSecrets, runtime.CLISecrets, runtime.WorkerSecrets, runtime.ApprovalSecrets} {
+		for token := range secrets {
+			result = append(result, token)
+		}
+	}
+	return result
+}
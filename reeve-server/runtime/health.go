@@ -0,0 +1,292 @@
+package runtime
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	goplugin "github.com/hashicorp/go-plugin"
+)
+
+// Phases of the server lifecycle reported by the health endpoints.
+const (
+	PHASE_STARTING        = "starting"
+	PHASE_LOADING_PLUGINS = "loading plugins"
+	PHASE_READY           = "ready"
+	PHASE_DRAINING        = "draining"
+)
+
+// A loop processing a single entry for longer than LOOP_STUCK_TIMEOUT is considered stuck.
+const LOOP_STUCK_TIMEOUT = 10 * time.Minute
+
+const PLUGIN_PING_TIMEOUT = 5 * time.Second
+
+// Plugins are pinged at most once per PLUGIN_PING_INTERVAL, readiness checks in between report the cached result.
+const PLUGIN_PING_INTERVAL = 30 * time.Second
+
+const (
+	HEALTH_OK      = "ok"
+	HEALTH_FAILING = "failing"
+)
+
+// Health tracks the startup phase of the server and the liveness of its queue loops.
+type Health struct {
+	lock  sync.Mutex
+	phase string
+	loops map[string]*LoopHealth
+
+	pingLock sync.Mutex
+	pingedAt time.Time
+	plugins  map[string]ComponentHealth
+}
+
+func NewHealth() *Health {
+	return &Health{phase: PHASE_STARTING, loops: make(map[string]*LoopHealth)}
+}
+
+func (h *Health) SetPhase(phase string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.phase = phase
+}
+
+func (h *Health) Phase() string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.phase
+}
+
+// SetStarted marks startup as completed.
+func (h *Health) SetStarted() {
+	h.SetPhase(PHASE_READY)
+}
+
+// Started returns true once startup has completed.
+func (h *Health) Started() bool {
+	return h.Phase() == PHASE_READY
+}
+
+// Loop registers a queue loop with the given name.
+func (h *Health) Loop(name string) *LoopHealth {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	loop := &LoopHealth{}
+	h.loops[name] = loop
+	return loop
+}
+
+// LoopHealth records whether a queue loop is waiting for entries, processing an entry or has exited.
+type LoopHealth struct {
+	lock      sync.Mutex
+	busySince time.Time
+	exited    bool
+}
+
+// Busy marks the loop as processing an entry.
+func (l *LoopHealth) Busy() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.busySince = time.Now()
+}
+
+// Idle marks the loop as waiting for the next entry.
+func (l *LoopHealth) Idle() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.busySince = time.Time{}
+}
+
+func (l *LoopHealth) Exit() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.busySince = time.Time{}
+	l.exited = true
+}
+
+func (l *LoopHealth) check(draining bool) ComponentHealth {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	switch {
+	case l.exited && !draining:
+		return ComponentHealth{Status: HEALTH_FAILING, Error: "loop exited"}
+
+	case !l.busySince.IsZero():
+		busySince := l.busySince
+		if time.Since(busySince) > LOOP_STUCK_TIMEOUT {
+			return ComponentHealth{Status: HEALTH_FAILING, Error: fmt.Sprintf("processing a single entry for more than %v", LOOP_STUCK_TIMEOUT), BusySince: &busySince}
+		}
+		return ComponentHealth{Status: HEALTH_OK, BusySince: &busySince}
+
+	default:
+		return ComponentHealth{Status: HEALTH_OK}
+	}
+}
+
+type HealthReport struct {
+	// Live is false if the server needs to be restarted.
+	Live bool `json:"live"`
+	// Ready is false while the server is starting, draining or unable to accept new work.
+	Ready bool `json:"ready"`
+
+	Phase   string                     `json:"phase"`
+	Plugins map[string]ComponentHealth `json:"plugins,omitempty"`
+	Loops   map[string]ComponentHealth `json:"loops,omitempty"`
+	Queues  QueueHealth                `json:"queues"`
+}
+
+type ComponentHealth struct {
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	BusySince *time.Time `json:"busySince,omitempty"`
+}
+
+type QueueHealth struct {
+	Messages QueueStatus                  `json:"messages"`
+	Triggers QueueStatus                  `json:"triggers"`
+	Notify   QueueStatus                  `json:"notify"`
+	Plugins  map[string]QueueStatus       `json:"plugins,omitempty"`
+	Workers  map[string]WorkerQueueStatus `json:"workers"`
+}
+
+type QueueStatus struct {
+	Count    uint `json:"count"`
+	Capacity uint `json:"capacity,omitempty"`
+	Full     bool `json:"full,omitempty"`
+}
+
+type WorkerQueueStatus struct {
+	Enqueued    uint `json:"enqueued"`
+	Running     int  `json:"running"`
+	Concurrency int  `json:"concurrency,omitempty"`
+}
+
+func boundedQueueStatus[T any](q *BoundedQueue[T]) QueueStatus {
+	return QueueStatus{Count: q.Count(), Capacity: q.Capacity, Full: q.Full()}
+}
+
+// CheckHealth reports the state of queue loops and queues.
+// Plugins are not pinged, so that a slow plugin can not cause the server to be restarted.
+func (runtime *Runtime) CheckHealth() HealthReport {
+	phase := runtime.Health.Phase()
+	draining := runtime.Draining()
+	if draining {
+		phase = PHASE_DRAINING
+	}
+
+	report := HealthReport{
+		Live:  true,
+		Phase: phase,
+		Loops: make(map[string]ComponentHealth),
+	}
+
+	runtime.Health.lock.Lock()
+	for name, loop := range runtime.Health.loops {
+		report.Loops[name] = loop.check(draining)
+	}
+	runtime.Health.lock.Unlock()
+
+	for _, loop := range report.Loops {
+		if loop.Status != HEALTH_OK {
+			report.Live = false
+		}
+	}
+
+	if phase == PHASE_STARTING || phase == PHASE_LOADING_PLUGINS {
+		return report
+	}
+
+	report.Queues = QueueHealth{
+		Messages: boundedQueueStatus(runtime.MessageQueue),
+		Triggers: boundedQueueStatus(runtime.TriggerQueue),
+		Notify:   boundedQueueStatus(runtime.NotifyQueue),
+		Plugins:  make(map[string]QueueStatus, len(runtime.MessageQueues)),
+		Workers:  make(map[string]WorkerQueueStatus, len(runtime.WorkerQueues)),
+	}
+	for name, queue := range runtime.MessageQueues {
+		report.Queues.Plugins[name] = boundedQueueStatus(queue)
+	}
+	for group, queue := range runtime.WorkerQueues {
+		report.Queues.Workers[group] = WorkerQueueStatus{
+			Enqueued:    queue.Count(),
+			Running:     runtime.Activity[group].Running(),
+			Concurrency: queue.Concurrency,
+		}
+	}
+
+	report.Ready = report.Live && phase == PHASE_READY && !report.Queues.Messages.Full && !report.Queues.Triggers.Full
+	return report
+}
+
+// CheckReady extends the health report with the state of plugins, the server is not ready while a plugin is not responding.
+// Plugins are only checked once loaded, each of them must respond to a ping within PLUGIN_PING_TIMEOUT.
+func (runtime *Runtime) CheckReady() HealthReport {
+	report := runtime.CheckHealth()
+	if report.Phase == PHASE_STARTING || report.Phase == PHASE_LOADING_PLUGINS {
+		return report
+	}
+
+	report.Plugins = runtime.pingPlugins()
+	for _, plugin := range report.Plugins {
+		if plugin.Status != HEALTH_OK {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+// pingPlugins returns the result of the last plugin ping, pinging the plugins again if it is older than PLUGIN_PING_INTERVAL.
+// Concurrent checks wait for the same ping.
+func (runtime *Runtime) pingPlugins() map[string]ComponentHealth {
+	runtime.Health.pingLock.Lock()
+	defer runtime.Health.pingLock.Unlock()
+
+	if runtime.Health.plugins == nil || time.Since(runtime.Health.pingedAt) >= PLUGIN_PING_INTERVAL {
+		runtime.Health.plugins = runtime.PluginProvider.Ping(PLUGIN_PING_TIMEOUT)
+		runtime.Health.pingedAt = time.Now()
+	}
+	return runtime.Health.plugins
+}
+
+// Ping checks whether all plugin processes are still responding.
+func (p *PluginProvider) Ping(timeout time.Duration) map[string]ComponentHealth {
+	result := make(map[string]ComponentHealth, len(p.Clients))
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(p.Clients))
+
+	for name, client := range p.Clients {
+		go func(name string, client goplugin.ClientProtocol) {
+			defer wg.Done()
+
+			health := ComponentHealth{Status: HEALTH_OK}
+
+			done := make(chan error, 1)
+			go func() {
+				done <- client.Ping()
+			}()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					health = ComponentHealth{Status: HEALTH_FAILING, Error: err.Error()}
+				}
+			case <-time.After(timeout):
+				health = ComponentHealth{Status: HEALTH_FAILING, Error: fmt.Sprintf("no response within %v", timeout)}
+			}
+
+			lock.Lock()
+			result[name] = health
+			lock.Unlock()
+		}(name, client)
+	}
+
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,110 @@
+package runtime
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/reeveci/reeve-lib/schema"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ParkedTrigger is a trigger matching a trigger approval rule, its pipelines are not enqueued before it has been approved.
+type ParkedTrigger struct {
+	ID      string         `json:"id"`
+	Trigger schema.Trigger `json:"trigger"`
+	Since   time.Time      `json:"since"`
+	// Approvers contains the names of the approval tokens which may decide, any approval token may decide if empty.
+	Approvers []string `json:"approvers,omitempty"`
+	// Pipelines contains the names of the pipelines discovered for the trigger.
+	Pipelines []string `json:"pipelines"`
+
+	// Discovered contains the discovered pipelines with secrets censored, which are used for notifications.
+	Discovered []schema.Pipeline `json:"-"`
+	Trace      trace.SpanContext `json:"-"`
+}
+
+// MayDecide returns true if the approval token with the given name may decide.
+func (p *ParkedTrigger) MayDecide(identity string) bool {
+	return len(p.Approvers) == 0 || slices.Contains(p.Approvers, identity)
+}
+
+// ParkedTriggers holds the triggers waiting for approval.
+type ParkedTriggers struct {
+	lock     sync.Mutex
+	triggers map[string]*ParkedTrigger
+}
+
+func NewParkedTriggers() *ParkedTriggers {
+	return &ParkedTriggers{triggers: make(map[string]*ParkedTrigger)}
+}
+
+// Park adds a trigger, assigning its ID.
+func (p *ParkedTriggers) Park(trigger ParkedTrigger) *ParkedTrigger {
+	trigger.ID = uuid.NewString()
+	trigger.Since = time.Now()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.triggers[trigger.ID] = &trigger
+	return &trigger
+}
+
+func (p *ParkedTriggers) Get(id string) *ParkedTrigger {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.triggers[id]
+}
+
+// Take removes a trigger, so that it can be decided only once.
+func (p *ParkedTriggers) Take(id string) *ParkedTrigger {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	trigger := p.triggers[id]
+	delete(p.triggers, id)
+	return trigger
+}
+
+// List returns all parked triggers, oldest first.
+func (p *ParkedTriggers) List() []*ParkedTrigger {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	result := make([]*ParkedTrigger, 0, len(p.triggers))
+	for _, trigger := range p.triggers {
+		result = append(result, trigger)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Since.Before(result[j].Since)
+	})
+	return result
+}
+
+// Decidable returns the parked triggers which may be decided by the approval token with the given name, oldest first.
+func (p *ParkedTriggers) Decidable(identity string) []*ParkedTrigger {
+	result := make([]*ParkedTrigger, 0)
+	for _, trigger := range p.List() {
+		if trigger.MayDecide(identity) {
+			result = append(result, trigger)
+		}
+	}
+	return result
+}
+
+// PopAll removes and returns all parked triggers, oldest first.
+func (p *ParkedTriggers) PopAll() []*ParkedTrigger {
+	result := p.List()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, trigger := range result {
+		delete(p.triggers, trigger.ID)
+	}
+	return result
+}
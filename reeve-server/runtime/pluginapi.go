@@ -1,7 +1,11 @@
 package runtime
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/reeveci/reeve-lib/schema"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func NewPluginAPI(plugin string, runtime *Runtime) *PluginAPI {
@@ -11,19 +15,46 @@ func NewPluginAPI(plugin string, runtime *Runtime) *PluginAPI {
 type PluginAPI struct {
 	Plugin  string
 	Runtime *Runtime
+
+	traceLock sync.Mutex
+	trace     trace.SpanContext
+}
+
+// SetTrace sets the trace messages and triggers sent by the plugin are attributed to.
+// It is set while the plugin handles a message, so that triggers caused by the message continue its trace.
+func (api *PluginAPI) SetTrace(trace trace.SpanContext) {
+	api.traceLock.Lock()
+	defer api.traceLock.Unlock()
+
+	api.trace = trace
+}
+
+func (api *PluginAPI) currentTrace() trace.SpanContext {
+	api.traceLock.Lock()
+	defer api.traceLock.Unlock()
+
+	return api.trace
 }
 
 func (api *PluginAPI) NotifyMessages(messages []schema.Message) error {
+	trace := api.currentTrace()
+
 	for _, message := range messages {
-		api.Runtime.MessageQueue.Push(schema.FullMessage{Message: message, Source: api.Plugin})
+		if !api.Runtime.MessageQueue.TryPush(QueuedMessage{FullMessage: schema.FullMessage{Message: message, Source: api.Plugin}, Trace: trace}) {
+			return fmt.Errorf("message queue is full")
+		}
 	}
 
 	return nil
 }
 
 func (api *PluginAPI) NotifyTriggers(triggers []schema.Trigger) error {
+	trace := api.currentTrace()
+
 	for _, trigger := range triggers {
-		api.Runtime.TriggerQueue.Push(trigger)
+		if !api.Runtime.TriggerQueue.TryPush(QueuedTrigger{Trigger: trigger, Trace: trace}) {
+			return fmt.Errorf("trigger queue is full")
+		}
 	}
 
 	return nil
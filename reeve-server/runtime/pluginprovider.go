@@ -8,14 +8,12 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/hashicorp/go-hclog"
 	goplugin "github.com/hashicorp/go-plugin"
 	"github.com/reeveci/reeve-lib/plugin"
-	"github.com/reeveci/reeve-lib/queue"
-	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-server/logging"
 )
 
-const SHARED_SETTING_PREFIX = "REEVE_SHARED_"
-
 type CLIPlugin struct {
 	plugin.Plugin
 	CLIMethods map[string]string
@@ -29,6 +27,8 @@ type PluginProvider struct {
 	ResolvePlugins  map[string]plugin.Plugin
 	NotifyPlugins   map[string]plugin.Plugin
 	CLIPlugins      map[string]CLIPlugin
+
+	Clients map[string]goplugin.ClientProtocol
 }
 
 func (p *PluginProvider) Close() {
@@ -49,6 +49,8 @@ func (p *PluginProvider) Close() {
 }
 
 func (runtime *Runtime) LoadPlugins() error {
+	runtime.Health.SetPhase(PHASE_LOADING_PLUGINS)
+
 	plugin.RegisterSharedTypes()
 
 	var pluginPaths []string
@@ -57,16 +59,29 @@ func (runtime *Runtime) LoadPlugins() error {
 		return err
 	}
 
-	runtime.MessageQueues = make(map[string]queue.Queue[schema.FullMessage], len(pluginPaths))
+	runtime.MessageQueues = make(map[string]*BoundedQueue[QueuedMessage], len(pluginPaths))
+	runtime.PluginAPIs = make(map[string]*PluginAPI, len(pluginPaths))
 	runtime.PluginProvider.Plugins = make(map[string]plugin.Plugin, len(pluginPaths))
 	runtime.PluginProvider.MessagePlugins = make(map[string]plugin.Plugin, len(pluginPaths))
 	runtime.PluginProvider.DiscoverPlugins = make(map[string]plugin.Plugin, len(pluginPaths))
 	runtime.PluginProvider.ResolvePlugins = make(map[string]plugin.Plugin, len(pluginPaths))
 	runtime.PluginProvider.NotifyPlugins = make(map[string]plugin.Plugin, len(pluginPaths))
 	runtime.PluginProvider.CLIPlugins = make(map[string]CLIPlugin, len(pluginPaths))
+	runtime.PluginProvider.Clients = make(map[string]goplugin.ClientProtocol, len(pluginPaths))
 
 	nameRegex := regexp.MustCompile("^[a-zA-Z0-9]+$")
 
+	// plugin output is passed through go-plugin's logger, which only needs to be replaced for structured logging
+	var pluginLogger hclog.Logger
+	if runtime.Config.LogFormat == logging.FORMAT_JSON {
+		pluginLogger = hclog.New(&hclog.LoggerOptions{
+			Name:       "plugin",
+			Output:     os.Stderr,
+			Level:      hclog.Trace,
+			JSONFormat: true,
+		})
+	}
+
 	for _, path := range pluginPaths {
 		cmd := exec.Command(path)
 		for _, env := range os.Environ() {
@@ -83,6 +98,7 @@ func (runtime *Runtime) LoadPlugins() error {
 			Cmd:             cmd,
 			SkipHostEnv:     true,
 			Managed:         true,
+			Logger:          pluginLogger,
 		})
 
 		rpcClient, clientErr := client.Client()
@@ -116,6 +132,7 @@ func (runtime *Runtime) LoadPlugins() error {
 		}
 
 		runtime.PluginProvider.Plugins[name] = plugin
+		runtime.PluginProvider.Clients[name] = rpcClient
 	}
 
 	var wg sync.WaitGroup
@@ -130,23 +147,14 @@ func (runtime *Runtime) LoadPlugins() error {
 		go func() {
 			defer wg.Done()
 
-			settings := make(map[string]string)
-			pluginPrefix := fmt.Sprintf("REEVE_PLUGIN_%s_", strings.ToUpper(name))
-			for _, env := range os.Environ() {
-				origKey := strings.Split(env, "=")[0]
-				key := strings.ToUpper(origKey)
-				if strings.HasPrefix(key, SHARED_SETTING_PREFIX) && len(key) > len(SHARED_SETTING_PREFIX) {
-					settingName := strings.TrimPrefix(key, SHARED_SETTING_PREFIX)
-					if _, ok := settings[settingName]; !ok {
-						settings[settingName] = os.Getenv(origKey)
-					}
-				}
-				if strings.HasPrefix(key, pluginPrefix) && len(key) > len(pluginPrefix) {
-					settings[strings.TrimPrefix(key, pluginPrefix)] = os.Getenv(origKey)
-				}
-			}
+			settings := runtime.Config.PluginSettings(name)
+
+			api := NewPluginAPI(name, runtime)
+			lock.Lock()
+			runtime.PluginAPIs[name] = api
+			lock.Unlock()
 
-			config, err := plugin.Register(settings, NewPluginAPI(name, runtime))
+			config, err := plugin.Register(settings, api)
 			if err != nil {
 				errors <- fmt.Errorf("registering plugin %s failed - %s", name, err)
 				return
@@ -154,7 +162,7 @@ func (runtime *Runtime) LoadPlugins() error {
 
 			lock.Lock()
 			if config.Message {
-				runtime.MessageQueues[name] = queue.Blocked(queue.NewQueue[schema.FullMessage]())
+				runtime.MessageQueues[name] = NewBoundedQueue[QueuedMessage](uint(runtime.Config.Limits.QueueSize))
 				runtime.PluginProvider.MessagePlugins[name] = plugin
 			}
 			if config.Discover {
@@ -0,0 +1,137 @@
+package runtime
+
+import (
+	"sync"
+
+	"github.com/reeveci/reeve-lib/queue"
+)
+
+// NewBoundedQueue creates a blocking queue, which refuses new entries via TryPush once it holds capacity entries.
+// A capacity of 0 disables the limit.
+func NewBoundedQueue[T any](capacity uint) *BoundedQueue[T] {
+	q := &BoundedQueue[T]{queue: queue.NewQueue[T](), Capacity: capacity}
+	q.cond = sync.NewCond(&q.lock)
+	return q
+}
+
+type BoundedQueue[T any] struct {
+	Capacity uint
+
+	lock   sync.Mutex
+	cond   *sync.Cond
+	queue  queue.Queue[T]
+	closed bool
+}
+
+// Get returns the first entry without removing it, blocking until an entry is available.
+// Once the queue is closed, Get returns the zero value instead of blocking.
+func (q *BoundedQueue[T]) Get() (result T) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for q.queue.Count() == 0 {
+		if q.closed {
+			return
+		}
+		q.cond.Wait()
+	}
+
+	return q.queue.Get()
+}
+
+// Pop removes and returns the first entry, blocking until an entry is available.
+// Once the queue is closed, Pop returns the zero value instead of blocking.
+func (q *BoundedQueue[T]) Pop() (result T) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for q.queue.Count() == 0 {
+		if q.closed {
+			return
+		}
+		q.cond.Wait()
+	}
+
+	return q.queue.Pop()
+}
+
+// Next removes and returns the first entry, blocking until an entry is available.
+// It returns false once the queue is closed and empty.
+func (q *BoundedQueue[T]) Next() (result T, ok bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for q.queue.Count() == 0 {
+		if q.closed {
+			return
+		}
+		q.cond.Wait()
+	}
+
+	return q.queue.Pop(), true
+}
+
+func (q *BoundedQueue[T]) Push(value T) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.queue.Push(value)
+	q.cond.Broadcast()
+}
+
+// TryPush adds value to the queue unless the queue is full.
+func (q *BoundedQueue[T]) TryPush(value T) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.Capacity > 0 && q.queue.Count() >= q.Capacity {
+		return false
+	}
+
+	q.queue.Push(value)
+	q.cond.Broadcast()
+	return true
+}
+
+func (q *BoundedQueue[T]) Count() uint {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.queue.Count()
+}
+
+func (q *BoundedQueue[T]) Full() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.Capacity > 0 && q.queue.Count() >= q.Capacity
+}
+
+// Close wakes up all blocked consumers.
+// Remaining entries can still be consumed, but consumers will no longer block on an empty queue.
+func (q *BoundedQueue[T]) Close() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+func (q *BoundedQueue[T]) Closed() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.closed
+}
+
+// PopAll removes and returns all remaining entries without blocking.
+func (q *BoundedQueue[T]) PopAll() []T {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	result := make([]T, 0, q.queue.Count())
+	for q.queue.Count() > 0 {
+		result = append(result, q.queue.Pop())
+	}
+	return result
+}
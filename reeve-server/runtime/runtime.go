@@ -1,120 +1,213 @@
 package runtime
 
 import (
-	"fmt"
+	"context"
 	"io"
-	"log"
-	"os"
+	"log/slog"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/reeveci/reeve-lib/exe"
 	"github.com/reeveci/reeve-lib/filter"
-	"github.com/reeveci/reeve-lib/queue"
 	"github.com/reeveci/reeve-lib/schema"
 	"github.com/reeveci/reeve/reeve-server/activity"
+	"github.com/reeveci/reeve/reeve-server/artifacts"
+	"github.com/reeveci/reeve/reeve-server/audit"
+	"github.com/reeveci/reeve/reeve-server/config"
+	"github.com/reeveci/reeve/reeve-server/logging"
+	"github.com/reeveci/reeve/reeve-server/ratelimit"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const TIMEOUT_QUEUE = 1 * time.Minute
-const TIMEOUT_ACTIVITY = 2 * time.Minute
-
 type ContractQueue[T any] struct {
-	queue.Queue[T]
+	*BoundedQueue[T]
 	Contract Contract
+
+	Timeout     time.Duration
+	Concurrency int
+}
+
+// QueuedMessage is a message waiting to be delivered, carrying the trace it belongs to.
+type QueuedMessage struct {
+	schema.FullMessage
+	Trace trace.SpanContext `json:"-"`
+}
+
+// QueuedTrigger is a trigger waiting to be processed, carrying the trace it belongs to.
+type QueuedTrigger struct {
+	Trigger schema.Trigger
+	Trace   trace.SpanContext
+	// Approved is set for parked triggers which have been approved, so that trigger approval rules are not applied again.
+	Approved bool
 }
 
 type Runtime struct {
+	Config *config.Config
+
 	PluginDirectory     string
 	HTTPPort, HTTPSPort string
 	PathPrefix          string
 	TLSCert, TLSKey     string
 
-	Log, ProcLog, ErrorLog *log.Logger
+	// Log reports pipeline status, ProcLog reports the server process, ErrorLog reports errors and PipelineLog echoes pipeline logs.
+	Log, ProcLog, ErrorLog, PipelineLog *slog.Logger
+
+	MessageSecrets  map[string]string
+	CLISecrets      map[string]string
+	WorkerSecrets   map[string]string
+	ApprovalSecrets map[string]string
+	WorkerGroups    map[string]bool
 
-	MessageSecrets map[string]bool
-	CLISecrets     map[string]bool
-	WorkerSecrets  map[string]bool
-	WorkerGroups   map[string]bool
+	AuditFile       string
+	AuditMaxSize    int64
+	AuditMaxBackups int
+	AuditTarget     string
+	Audit           *audit.Auditor
 
-	MessageQueue queue.Queue[schema.FullMessage]
-	StatusQueue  queue.Queue[schema.PipelineStatus]
-	TriggerQueue queue.Queue[schema.Trigger]
-	NotifyQueue  queue.Queue[schema.PipelineStatus]
+	TokenLimiter   *ratelimit.Limiter
+	TargetLimiter  *ratelimit.Limiter
+	MessageMaxSize int64
 
-	MessageQueues map[string]queue.Queue[schema.FullMessage]
+	// Artifacts stores the artifacts published by pipelines.
+	Artifacts *artifacts.Store
+
+	MessageQueue *BoundedQueue[QueuedMessage]
+	TriggerQueue *BoundedQueue[QueuedTrigger]
+	NotifyQueue  *BoundedQueue[schema.PipelineStatus]
+
+	ParkedTriggers *ParkedTriggers
+
+	MessageQueues map[string]*BoundedQueue[QueuedMessage]
 	WorkerQueues  map[string]*ContractQueue[activity.PipelineActivity]
 	Activity      map[string]*activity.RuntimeActivity
 
-	QueueTimeout time.Duration
-
 	PluginProvider PluginProvider
+	PluginAPIs     map[string]*PluginAPI
 
-	Status chan []string
+	Status chan slog.Record
+
+	Health *Health
+
+	// Loops tracks the goroutines processing the trigger and message queues.
+	Loops sync.WaitGroup
+
+	drain     chan struct{}
+	drainOnce sync.Once
 }
 
-func GetRuntime() *Runtime {
+// GetRuntime sets up the runtime from a validated configuration.
+func GetRuntime(cfg *config.Config) *Runtime {
+	tokenRate, _ := ratelimit.ParseRate(cfg.Limits.MessageTokenRate)
+	targetRate, _ := ratelimit.ParseRate(cfg.Limits.MessageTargetRate)
+
 	runtime := Runtime{
-		PluginDirectory: exe.GetEnvDef("REEVE_PLUGIN_DIRECTORY", "./plugins"),
-		HTTPPort:        exe.GetEnvDef("REEVE_HTTP_PORT", ""),
-		HTTPSPort:       exe.GetEnvDef("REEVE_HTTPS_PORT", ""),
+		Config: cfg,
+
+		PluginDirectory: cfg.PluginDirectory,
+		HTTPPort:        cfg.HTTPPort,
+		HTTPSPort:       cfg.HTTPSPort,
 		PathPrefix:      "/api/v1",
-		TLSCert:         exe.GetEnvDef("REEVE_TLS_CERT_FILE", ""),
-		TLSKey:          exe.GetEnvDef("REEVE_TLS_KEY_FILE", ""),
+		TLSCert:         cfg.TLS.CertFile,
+		TLSKey:          cfg.TLS.KeyFile,
+
+		MessageSecrets:  config.TokenMap(cfg.Secrets.Message),
+		CLISecrets:      config.TokenMap(cfg.Secrets.CLI),
+		WorkerSecrets:   config.TokenMap(cfg.Secrets.Worker),
+		ApprovalSecrets: config.TokenMap(cfg.Secrets.Approval),
+		WorkerGroups:    make(map[string]bool, len(cfg.WorkerGroups)+1),
+
+		AuditFile:       cfg.Audit.File,
+		AuditMaxSize:    int64(cfg.Audit.MaxSize) * 1024 * 1024,
+		AuditMaxBackups: cfg.Audit.MaxBackups,
+		AuditTarget:     cfg.Audit.Target,
+		Audit:           &audit.Auditor{},
+
+		TokenLimiter:   ratelimit.NewLimiter(tokenRate),
+		TargetLimiter:  ratelimit.NewLimiter(targetRate),
+		MessageMaxSize: int64(cfg.Limits.MessageMaxSize) * 1024 * 1024,
 
-		MessageSecrets: exe.GetEnvFieldMap("REEVE_MESSAGE_SECRETS", ""),
-		CLISecrets:     exe.GetEnvFieldMap("REEVE_CLI_SECRETS", ""),
-		WorkerSecrets:  exe.GetEnvFieldMap("REEVE_WORKER_SECRETS", ""),
-		WorkerGroups:   exe.GetEnvFieldMap("REEVE_WORKER_GROUPS", ""),
+		Artifacts: artifacts.NewStore(cfg.Artifacts.Directory, cfg.Artifacts.Retention, int64(cfg.Artifacts.MaxSize)*1024*1024),
 
-		MessageQueue: queue.Blocked(queue.NewQueue[schema.FullMessage]()),
-		TriggerQueue: queue.Blocked(queue.NewQueue[schema.Trigger]()),
-		NotifyQueue:  queue.Blocked(queue.NewQueue[schema.PipelineStatus]()),
+		MessageQueue: NewBoundedQueue[QueuedMessage](uint(cfg.Limits.QueueSize)),
+		TriggerQueue: NewBoundedQueue[QueuedTrigger](uint(cfg.Limits.QueueSize)),
+		NotifyQueue:  NewBoundedQueue[schema.PipelineStatus](uint(cfg.Limits.QueueSize)),
 
-		QueueTimeout: TIMEOUT_QUEUE,
+		ParkedTriggers: NewParkedTriggers(),
 
-		Status: make(chan []string, 20),
+		Status: make(chan slog.Record, 20),
+
+		Health: NewHealth(),
+
+		drain: make(chan struct{}),
 	}
 
 	if runtime.HTTPPort == "" && (runtime.HTTPSPort == "" || runtime.TLSCert == "" || runtime.TLSKey == "") {
 		runtime.HTTPPort = "9080"
 	}
 
+	for group := range cfg.WorkerGroups {
+		runtime.WorkerGroups[group] = true
+	}
 	runtime.WorkerGroups[schema.DEFAULT_WORKER_GROUP] = true
 	runtime.WorkerQueues = make(map[string]*ContractQueue[activity.PipelineActivity], len(runtime.WorkerGroups))
 	runtime.Activity = make(map[string]*activity.RuntimeActivity, len(runtime.WorkerGroups))
 	for group := range runtime.WorkerGroups {
-		runtime.WorkerQueues[group] = &ContractQueue[activity.PipelineActivity]{Queue: queue.Blocked(queue.NewQueue[activity.PipelineActivity]())}
+		groupConfig := cfg.Group(group)
+
+		runtime.WorkerQueues[group] = &ContractQueue[activity.PipelineActivity]{
+			BoundedQueue: NewBoundedQueue[activity.PipelineActivity](uint(cfg.Limits.QueueSize)),
+			Timeout:      groupConfig.QueueTimeout,
+			Concurrency:  groupConfig.Concurrency,
+		}
 
 		notifications := make(chan schema.PipelineStatus)
 
-		runtime.Activity[group] = activity.NewRuntimeActivity(group, TIMEOUT_ACTIVITY, notifications)
+		runtime.Activity[group] = activity.NewRuntimeActivity(group, groupConfig.Timeout, notifications)
+		runtime.Activity[group].LogLimits = activity.LogLimits{
+			MaxSize:     int64(cfg.Limits.LogMaxSize) * 1024 * 1024,
+			MaxLineSize: cfg.Limits.LogMaxLineSize * 1024,
+			Fail:        cfg.Limits.LogLimitPolicy == config.LOG_LIMIT_FAIL,
+		}
 
 		go func() {
+			// pipelines return to running after approval steps, their logs must only be echoed once
+			echoing := make(map[string]bool)
+
 			for {
 				status := <-notifications
 
-				runtime.NotifyQueue.Push(status)
+				runtime.TryPushNotification(status)
 
-				runtime.Status <- []string{fmt.Sprintf("[%s|%s: %s] %s", status.WorkerGroup, status.ActivityID, status.Pipeline.Name, status.Status)}
+				runtime.StatusUpdate(string(status.Status), logging.StatusAttrs(status)...)
 
 				switch status.Status {
 				case schema.STATUS_RUNNING:
+					if echoing[status.ActivityID] {
+						break
+					}
+					echoing[status.ActivityID] = true
+
 					go func() {
 						reader, err := status.Logs.Reader()
 						if err != nil {
-							fmt.Printf("### [%s|%s: %s] reading logs failed - %s\n", status.WorkerGroup, status.ActivityID, status.Pipeline.Name, err)
+							runtime.ErrorLog.Error("reading logs failed", append(logging.StatusAttrs(status), logging.Err(err))...)
 							return
 						}
 
 						defer reader.Close()
 
-						err = FilterPipeline(reader, os.Stdout, fmt.Sprintf("### [%s|%s] > ", status.WorkerGroup, status.ActivityID))
+						pipelineLog := runtime.PipelineLog.With(logging.KEY_WORKER_GROUP, status.WorkerGroup, logging.KEY_ACTIVITY, status.ActivityID, logging.KEY_PIPELINE, status.Pipeline.Name)
+						err = FilterPipeline(reader, func(line string) {
+							pipelineLog.Info(line)
+						})
 						if err != nil {
-							fmt.Printf("### [%s|%s: %s] reading logs failed - %s\n", status.WorkerGroup, status.ActivityID, status.Pipeline.Name, err)
+							runtime.ErrorLog.Error("reading logs failed", append(logging.StatusAttrs(status), logging.Err(err))...)
 						}
 					}()
 
-				case schema.STATUS_SUCCESS, schema.STATUS_FAILED, schema.STATUS_TIMEOUT:
+				case schema.STATUS_SUCCESS, schema.STATUS_FAILED, schema.STATUS_TIMEOUT, activity.STATUS_CANCELED:
+					delete(echoing, status.ActivityID)
 					runtime.LogQueueStatus()
 				}
 			}
@@ -124,40 +217,137 @@ func GetRuntime() *Runtime {
 	return &runtime
 }
 
+// TryPushNotification adds a status notification to the notify queue, notifications are dropped while the queue is full.
+func (runtime *Runtime) TryPushNotification(status schema.PipelineStatus) {
+	if !runtime.NotifyQueue.TryPush(status) {
+		runtime.ErrorLog.Error("dropping notification - notify queue is full", logging.StatusAttrs(status)...)
+	}
+}
+
+// TryPushMessage adds a message to the message queue unless the queue is full.
+// The message continues the trace of ctx.
+func (runtime *Runtime) TryPushMessage(ctx context.Context, message schema.FullMessage) bool {
+	return runtime.MessageQueue.TryPush(QueuedMessage{FullMessage: message, Trace: trace.SpanContextFromContext(ctx)})
+}
+
+// PushMessage adds a message to the message queue regardless of its capacity.
+func (runtime *Runtime) PushMessage(ctx context.Context, message schema.FullMessage) {
+	runtime.MessageQueue.Push(QueuedMessage{FullMessage: message, Trace: trace.SpanContextFromContext(ctx)})
+}
+
+// TryPushTrigger adds a trigger to the trigger queue unless the queue is full.
+// The trigger continues the trace of ctx.
+func (runtime *Runtime) TryPushTrigger(ctx context.Context, trigger schema.Trigger) bool {
+	return runtime.TriggerQueue.TryPush(QueuedTrigger{Trigger: trigger, Trace: trace.SpanContextFromContext(ctx)})
+}
+
+// PushTrigger adds a trigger to the trigger queue regardless of its capacity.
+func (runtime *Runtime) PushTrigger(ctx context.Context, trigger schema.Trigger) {
+	runtime.TriggerQueue.Push(QueuedTrigger{Trigger: trigger, Trace: trace.SpanContextFromContext(ctx)})
+}
+
+// PushApprovedTrigger adds an approved trigger to the trigger queue regardless of its capacity,
+// approvals are never rejected once the approver has decided.
+func (runtime *Runtime) PushApprovedTrigger(ctx context.Context, trigger schema.Trigger) {
+	runtime.TriggerQueue.Push(QueuedTrigger{Trigger: trigger, Trace: trace.SpanContextFromContext(ctx), Approved: true})
+}
+
+// Drain puts the runtime into drain mode.
+// New messages are refused, no more contracts are handed out or acknowledged and the trigger and message queues stop being processed.
+// Remaining entries stay in their queues.
+func (runtime *Runtime) Drain() {
+	runtime.drainOnce.Do(func() {
+		close(runtime.drain)
+
+		runtime.MessageQueue.Close()
+		runtime.TriggerQueue.Close()
+		for _, queue := range runtime.MessageQueues {
+			queue.Close()
+		}
+		for _, queue := range runtime.WorkerQueues {
+			queue.Close()
+			// acknowledgements are refused while draining, so pending contracts can be released right away
+			queue.Contract.Cancel()
+		}
+	})
+}
+
+func (runtime *Runtime) Draining() bool {
+	select {
+	case <-runtime.drain:
+		return true
+	default:
+		return false
+	}
+}
+
+// DrainContext returns a context which is canceled when ctx is done or the runtime starts draining.
+func (runtime *Runtime) DrainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-runtime.drain:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// WaitForActivities blocks until no activities are running anymore or ctx is done.
+func (runtime *Runtime) WaitForActivities(ctx context.Context) bool {
+	for _, activity := range runtime.Activity {
+		if !activity.WaitForCapacity(ctx, 1) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetupLogging creates the runtime loggers.
+func (runtime *Runtime) SetupLogging(handler slog.Handler) {
+	logger := slog.New(handler)
+	runtime.Log = logger.With(logging.KEY_KIND, logging.KIND_STATUS)
+	runtime.ProcLog = logger.With(logging.KEY_KIND, logging.KIND_PROCESS)
+	runtime.ErrorLog = logger.With(logging.KEY_KIND, logging.KIND_PROCESS)
+	runtime.PipelineLog = logger.With(logging.KEY_KIND, logging.KIND_PIPELINE)
+}
+
+// StatusUpdate queues a status message, which is logged by LogStatus.
+func (runtime *Runtime) StatusUpdate(msg string, args ...any) {
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0)
+	record.Add(args...)
+	runtime.Status <- record
+}
+
 func (runtime *Runtime) LogQueueStatus() {
 	total := uint(0)
-	lines := make([]string, 1+len(runtime.WorkerQueues))
-	i := 0
+	queues := make([]any, 0, len(runtime.WorkerQueues))
 
 	for group, queue := range runtime.WorkerQueues {
-		i += 1
 		count := queue.Count()
 		total += count
-		lines[i] = fmt.Sprintf("<status>   -> %s: %v", group, count)
+		queues = append(queues, slog.Uint64(group, uint64(count)))
 	}
 
-	lines[0] = fmt.Sprintf("<status> %v pipelines enqueued in:", total)
-
-	runtime.Status <- lines
+	runtime.StatusUpdate("pipelines enqueued", "total", total, slog.Group("queues", queues...))
 }
 
 func (runtime *Runtime) LogStatus() {
 	for {
-		lines := <-runtime.Status
+		record := <-runtime.Status
 
-		for _, line := range lines {
-			runtime.Log.Println(line)
-		}
+		runtime.Log.Handler().Handle(context.Background(), record)
 	}
 }
 
 var outputRegex *regexp.Regexp = regexp.MustCompile(`^\[(setup|step:\d+):>\]`)
 
-func FilterPipeline(r io.Reader, w io.Writer, prefix string) error {
-	return filter.LineFilter(r, w, func(line string) string {
-		if outputRegex.MatchString(line) {
-			return ""
+func FilterPipeline(r io.Reader, handleLine func(line string)) error {
+	return filter.LineFilter(r, io.Discard, func(line string) string {
+		if !outputRegex.MatchString(line) {
+			handleLine(strings.TrimSuffix(line, "\n"))
 		}
-		return prefix + line
+		return ""
 	})
 }
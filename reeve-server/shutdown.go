@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/reeveci/reeve/reeve-server/api"
+	"github.com/reeveci/reeve/reeve-server/logging"
+	"github.com/reeveci/reeve/reeve-server/runtime"
+)
+
+const NOTIFY_POLL_INTERVAL = 100 * time.Millisecond
+const SERVER_SHUTDOWN_TIMEOUT = 10 * time.Second
+
+// shutdown drains the runtime.
+// Running activities may finish within the configured shutdown timeout, remaining queue entries are persisted to the state file.
+func shutdown(runtime *runtime.Runtime, server *api.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), runtime.Config.ShutdownTimeout)
+	defer cancel()
+
+	runtime.Drain()
+
+	if !waitGroup(ctx, &runtime.Loops) {
+		runtime.ErrorLog.Error("shutdown timeout exceeded while waiting for queue processing to stop")
+	}
+
+	runtime.ProcLog.Info("waiting for running activities to finish")
+	if !runtime.WaitForActivities(ctx) {
+		running := 0
+		for _, activity := range runtime.Activity {
+			running += activity.Running()
+		}
+		runtime.ErrorLog.Error("shutdown timeout exceeded - abandoning running activities", "running", running)
+	}
+
+	state := collectState(runtime)
+	if !state.Empty() {
+		if runtime.Config.StateFile == "" {
+			runtime.ErrorLog.Error("no state file configured - dropping queued work", "messages", len(state.Messages), "triggers", len(state.Triggers)+len(state.ApprovedTriggers), "pipelines", len(state.Pipelines))
+		} else if err := saveState(runtime.Config.StateFile, state); err != nil {
+			runtime.ErrorLog.Error("error persisting state", logging.Err(err))
+		} else {
+			runtime.ProcLog.Info("persisted queued work", "messages", len(state.Messages), "triggers", len(state.Triggers)+len(state.ApprovedTriggers), "pipelines", len(state.Pipelines), "file", runtime.Config.StateFile)
+		}
+	}
+
+	// deliver pending notifications before plugins are closed
+	for runtime.NotifyQueue.Count() > 0 && ctx.Err() == nil {
+		time.Sleep(NOTIFY_POLL_INTERVAL)
+	}
+
+	serverCtx, serverCancel := context.WithTimeout(context.Background(), SERVER_SHUTDOWN_TIMEOUT)
+	defer serverCancel()
+
+	err := server.Shutdown(serverCtx)
+	if err != nil {
+		runtime.ErrorLog.Error("error shutting down API server", logging.Err(err))
+	}
+
+	runtime.ProcLog.Info("shutdown complete")
+}
+
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
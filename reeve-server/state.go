@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-server/logging"
+	"github.com/reeveci/reeve/reeve-server/runtime"
+	"github.com/reeveci/reeve/reeve-server/triggers"
+)
+
+// serverState contains all queued work which has not been processed when the server shut down.
+// Secret environment values are never written to disk, they are resolved again on restore using the plugin they have been resolved by.
+type serverState struct {
+	Messages []schema.FullMessage `json:"messages,omitempty"`
+	Triggers []schema.Trigger     `json:"triggers,omitempty"`
+	// ApprovedTriggers have been parked and approved, they are not parked again.
+	ApprovedTriggers []schema.Trigger `json:"approvedTriggers,omitempty"`
+	Pipelines        []queuedPipeline `json:"pipelines,omitempty"`
+}
+
+type queuedPipeline struct {
+	WorkerGroup string          `json:"workerGroup"`
+	Pipeline    schema.Pipeline `json:"pipeline"`
+	// SecretSources contains the resolve plugin of each secret env, see activity.PipelineActivity.
+	SecretSources map[string]string `json:"secretSources,omitempty"`
+}
+
+func (s *serverState) Empty() bool {
+	return len(s.Messages) == 0 && len(s.Triggers) == 0 && len(s.ApprovedTriggers) == 0 && len(s.Pipelines) == 0
+}
+
+// collectState removes all remaining entries from the runtime queues.
+// The runtime must be draining and the queue loops must have exited.
+func collectState(runtime *runtime.Runtime) *serverState {
+	state := &serverState{}
+
+	for _, message := range runtime.MessageQueue.PopAll() {
+		state.Messages = append(state.Messages, message.FullMessage)
+	}
+
+	for name, queue := range runtime.MessageQueues {
+		for _, message := range queue.PopAll() {
+			message.Target = name
+			state.Messages = append(state.Messages, message.FullMessage)
+		}
+	}
+
+	for _, trigger := range runtime.TriggerQueue.PopAll() {
+		if trigger.Approved {
+			state.ApprovedTriggers = append(state.ApprovedTriggers, trigger.Trigger)
+		} else {
+			state.Triggers = append(state.Triggers, trigger.Trigger)
+		}
+	}
+
+	// parked triggers are parked again when being restored
+	for _, parked := range runtime.ParkedTriggers.PopAll() {
+		state.Triggers = append(state.Triggers, parked.Trigger)
+	}
+
+	for group, queue := range runtime.WorkerQueues {
+		for _, activity := range queue.PopAll() {
+			state.Pipelines = append(state.Pipelines, queuedPipeline{
+				WorkerGroup:   group,
+				Pipeline:      stripSecrets(activity.Pipeline),
+				SecretSources: activity.SecretSources,
+			})
+		}
+	}
+
+	return state
+}
+
+func stripSecrets(pipeline schema.Pipeline) schema.Pipeline {
+	env := make(map[string]schema.Env, len(pipeline.Env))
+
+	for key, value := range pipeline.Env {
+		if value.Secret {
+			value.Value = ""
+		}
+
+		env[key] = value
+	}
+
+	pipeline.Env = env
+	return pipeline
+}
+
+func saveState(file string, state *serverState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error encoding state - %s", err)
+	}
+
+	tmpFile := filepath.Join(filepath.Dir(file), fmt.Sprintf(".%s.tmp", filepath.Base(file)))
+	err = os.WriteFile(tmpFile, data, 0600)
+	if err != nil {
+		return fmt.Errorf("error writing state file - %s", err)
+	}
+
+	err = os.Rename(tmpFile, file)
+	if err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("error writing state file - %s", err)
+	}
+
+	return nil
+}
+
+// restoreState loads the state file and pushes all contained entries into the runtime queues.
+// The state file is removed afterwards, so that entries are not restored twice.
+func restoreState(runtime *runtime.Runtime, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading state file - %s", err)
+	}
+
+	var state serverState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return fmt.Errorf("error parsing state file - %s", err)
+	}
+
+	for _, message := range state.Messages {
+		runtime.PushMessage(context.Background(), message)
+	}
+
+	for _, trigger := range state.Triggers {
+		runtime.PushTrigger(context.Background(), trigger)
+	}
+
+	for _, trigger := range state.ApprovedTriggers {
+		runtime.PushApprovedTrigger(context.Background(), trigger)
+	}
+
+	// secrets are resolved again by the plugin they have been resolved by, pipelines with secrets defined by the pipeline itself are dropped
+	secretKeys := make(map[string]map[string]bool)
+	for _, pipeline := range state.Pipelines {
+		for key, value := range pipeline.Pipeline.Env {
+			if plugin, ok := pipeline.SecretSources[key]; value.Secret && ok {
+				if secretKeys[plugin] == nil {
+					secretKeys[plugin] = make(map[string]bool)
+				}
+				secretKeys[plugin][key] = true
+			}
+		}
+	}
+	resolvedSecretEnv := make(map[string]map[string]schema.Env, len(secretKeys))
+	for plugin, keys := range secretKeys {
+		env, err := triggers.ResolvePluginEnv(context.Background(), runtime, plugin, slices.Collect(maps.Keys(keys)))
+		if err != nil {
+			runtime.ErrorLog.Error("resolving secret env of restored pipelines failed", logging.KEY_PLUGIN, plugin, logging.Err(err))
+			continue
+		}
+		resolvedSecretEnv[plugin] = env
+	}
+
+	restoredPipelines := 0
+
+L:
+	for _, pipeline := range state.Pipelines {
+		queue, ok := runtime.WorkerQueues[pipeline.WorkerGroup]
+		if !ok {
+			runtime.ErrorLog.Error("dropping restored pipeline - worker group does not exist anymore", logging.KEY_WORKER_GROUP, pipeline.WorkerGroup, logging.KEY_PIPELINE, pipeline.Pipeline.Name)
+			continue
+		}
+
+		for key, value := range pipeline.Pipeline.Env {
+			if !value.Secret {
+				continue
+			}
+
+			plugin, ok := pipeline.SecretSources[key]
+			if !ok {
+				runtime.ErrorLog.Error("dropping restored pipeline - secret env has been defined by the pipeline", logging.KEY_WORKER_GROUP, pipeline.WorkerGroup, logging.KEY_PIPELINE, pipeline.Pipeline.Name, "env", key)
+				continue L
+			}
+
+			resolved, ok := resolvedSecretEnv[plugin][key]
+			if !ok || !resolved.Secret {
+				runtime.ErrorLog.Error("dropping restored pipeline - secret env could not be resolved", logging.KEY_WORKER_GROUP, pipeline.WorkerGroup, logging.KEY_PIPELINE, pipeline.Pipeline.Name, logging.KEY_PLUGIN, plugin, "env", key)
+				continue L
+			}
+			pipeline.Pipeline.Env[key] = resolved
+		}
+
+		activity := runtime.Activity[pipeline.WorkerGroup].RegisterPipeline(context.Background(), pipeline.Pipeline)
+		activity.SecretSources = pipeline.SecretSources
+		queue.Push(activity)
+		restoredPipelines += 1
+	}
+
+	err = os.Remove(file)
+	if err != nil {
+		return fmt.Errorf("error removing state file - %s", err)
+	}
+
+	runtime.ProcLog.Info("restored queued work", "messages", len(state.Messages), "triggers", len(state.Triggers)+len(state.ApprovedTriggers), "pipelines", restoredPipelines, "file", file)
+	return nil
+}
@@ -0,0 +1,100 @@
+package triggers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-common/tracing"
+	"github.com/reeveci/reeve/reeve-server/activity"
+	"github.com/reeveci/reeve/reeve-server/config"
+	"github.com/reeveci/reeve/reeve-server/logging"
+	"github.com/reeveci/reeve/reeve-server/runtime"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Statuses reported to notify plugins for the pipelines of parked triggers, the activity ID is the ID of the parked trigger.
+const (
+	STATUS_PENDING_APPROVAL schema.Status = "pending-approval"
+	STATUS_DISCARDED        schema.Status = "discarded"
+)
+
+// ApprovalRule returns the first trigger approval rule matching trigger, or nil if the trigger may be processed right away.
+func ApprovalRule(runtime *runtime.Runtime, trigger schema.Trigger) *config.TriggerApprovalRule {
+	for i, rule := range runtime.Config.TriggerApproval {
+		if matchRule(rule, trigger) {
+			return &runtime.Config.TriggerApproval[i]
+		}
+	}
+	return nil
+}
+
+func matchRule(rule config.TriggerApprovalRule, trigger schema.Trigger) bool {
+	for key, condition := range rule.When {
+		value, ok := trigger[key]
+		if !ok {
+			return false
+		}
+
+		ok, err := condition.Check(key, map[string]schema.Fact{key: {value}}, nil, nil)
+		if err != nil {
+			// conditions which can't be checked must not let triggers pass
+			continue
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// park discovers the pipelines of a trigger matching rule and holds the trigger until it has been approved.
+// Env is not resolved, so that no secrets are passed to the pipelines before approval.
+func park(ctx context.Context, runtime *runtime.Runtime, trigger schema.Trigger, rule *config.TriggerApprovalRule) {
+	ctx, span := tracing.Start(ctx, "park trigger")
+	defer span.End()
+
+	pipelines, _ := discoverPipelines(ctx, runtime, trigger)
+	if len(pipelines) == 0 {
+		return
+	}
+
+	parked := newParkedTrigger(trigger, rule, pipelines)
+	parked.Trace = trace.SpanContextFromContext(ctx)
+	parked = runtime.ParkedTriggers.Park(*parked)
+	span.SetAttributes(attribute.String("reeve.trigger", parked.ID))
+
+	runtime.Log.Info(fmt.Sprintf("trigger %s is waiting for approval", parked.ID), "pipelines", parked.Pipelines)
+	Notify(runtime, parked, STATUS_PENDING_APPROVAL)
+}
+
+// newParkedTrigger creates a parked trigger for the given discovered pipelines.
+func newParkedTrigger(trigger schema.Trigger, rule *config.TriggerApprovalRule, pipelines []*paramPipeline) *runtime.ParkedTrigger {
+	parked := &runtime.ParkedTrigger{
+		Trigger:    trigger,
+		Approvers:  rule.Approvers,
+		Pipelines:  make([]string, 0, len(pipelines)),
+		Discovered: make([]schema.Pipeline, 0, len(pipelines)),
+	}
+	for _, pipeline := range pipelines {
+		parked.Pipelines = append(parked.Pipelines, pipeline.Name)
+		parked.Discovered = append(parked.Discovered, activity.CensorSecrets(pipeline.Pipeline))
+	}
+	return parked
+}
+
+// Notify reports status for all pipelines of a parked trigger to notify plugins.
+func Notify(runtime *runtime.Runtime, parked *runtime.ParkedTrigger, status schema.Status) {
+	for _, pipeline := range parked.Discovered {
+		notification := schema.PipelineStatus{
+			Pipeline:   pipeline,
+			ActivityID: parked.ID,
+			Status:     status,
+		}
+		notification.Result.ExitCode = -1
+
+		runtime.TryPushNotification(notification)
+		runtime.StatusUpdate(string(status), logging.StatusAttrs(notification)...)
+	}
+}
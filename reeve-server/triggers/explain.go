@@ -0,0 +1,64 @@
+package triggers
+
+import (
+	"context"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-server/activity"
+	"github.com/reeveci/reeve/reeve-server/runtime"
+)
+
+// Explanation describes how a trigger would be processed.
+type Explanation struct {
+	Trigger   schema.Trigger        `json:"trigger"`
+	Pipelines []PipelineExplanation `json:"pipelines"`
+	Errors    []PluginError         `json:"errors,omitempty"`
+	// ApprovalRequired is set if the trigger matches a trigger approval rule, so that its pipelines are parked until approved.
+	ApprovalRequired bool     `json:"approvalRequired,omitempty"`
+	Approvers        []string `json:"approvers,omitempty"`
+}
+
+type PipelineExplanation struct {
+	Decision
+	// Definition is the pipeline with resolved env, secret values are redacted.
+	// Env is not resolved for triggers which require approval.
+	Definition schema.Pipeline `json:"definition"`
+}
+
+// Explain runs discovery, env resolution and condition checks for a trigger without enqueueing any pipelines.
+// Triggers matching a trigger approval rule are only discovered, like when they are parked, as env is not resolved before approval.
+func Explain(ctx context.Context, runtime *runtime.Runtime, trigger schema.Trigger) Explanation {
+	rule := ApprovalRule(runtime, trigger)
+
+	var pipelines []*paramPipeline
+	var pluginErrors []PluginError
+	if rule != nil {
+		pipelines, pluginErrors = discoverPipelines(ctx, runtime, trigger)
+		for _, pipeline := range pipelines {
+			pipeline.Result = RESULT_APPROVAL
+			pipeline.Reason = "env and conditions are evaluated once the trigger has been approved"
+		}
+	} else {
+		pipelines, pluginErrors = evaluate(ctx, runtime, trigger)
+	}
+
+	explanation := Explanation{
+		Trigger:   trigger,
+		Pipelines: make([]PipelineExplanation, 0, len(pipelines)),
+		Errors:    pluginErrors,
+	}
+
+	if rule != nil {
+		explanation.ApprovalRequired = true
+		explanation.Approvers = rule.Approvers
+	}
+
+	for _, pipeline := range pipelines {
+		explanation.Pipelines = append(explanation.Pipelines, PipelineExplanation{
+			Decision:   pipeline.Decision,
+			Definition: activity.CensorSecrets(pipeline.Pipeline),
+		})
+	}
+
+	return explanation
+}
@@ -0,0 +1,183 @@
+package triggers
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// Pipelines declare input parameters using conditions with INPUT_PREFIX in the form "input NAME [TYPE] [= DEFAULT]".
+// Inputs without default are required, the condition is used to validate the value.
+//
+//	when:
+//	  input VERSION:
+//	    match: ["^v[0-9]+"]
+//	  input DRY_RUN bool = false: {}
+//	  input TARGET choice = staging:
+//	    include: [staging, production]
+//
+// Input values are passed as trigger entries with the same prefix, e.g. "input VERSION": "v1",
+// and injected into the pipeline as env.
+const INPUT_PREFIX = "input "
+
+const (
+	INPUT_STRING = "string"
+	INPUT_BOOL   = "bool"
+	INPUT_CHOICE = "choice"
+)
+
+type Input struct {
+	Name       string
+	Type       string
+	Default    string
+	HasDefault bool
+	Condition  schema.Condition
+}
+
+func parseInput(key string, condition schema.Condition) (input Input, err error) {
+	declaration, defaultValue, hasDefault := strings.Cut(strings.TrimPrefix(key, INPUT_PREFIX), "=")
+
+	fields := strings.Fields(declaration)
+	switch len(fields) {
+	case 1:
+		input.Type = INPUT_STRING
+	case 2:
+		input.Type = fields[1]
+	default:
+		return input, fmt.Errorf("invalid input declaration %q", key)
+	}
+	input.Name = fields[0]
+	input.Condition = condition
+
+	if hasDefault {
+		input.HasDefault = true
+		input.Default = strings.TrimSpace(defaultValue)
+	}
+
+	switch input.Type {
+	case INPUT_STRING:
+	case INPUT_BOOL:
+	case INPUT_CHOICE:
+		if len(condition.Include) == 0 {
+			return input, fmt.Errorf("input %s is a choice but does not include any values", input.Name)
+		}
+	default:
+		return input, fmt.Errorf("input %s has unknown type %s", input.Name, input.Type)
+	}
+
+	if input.HasDefault {
+		input.Default, err = input.validate(input.Default)
+		if err != nil {
+			return input, fmt.Errorf("invalid default value for input %s - %s", input.Name, err)
+		}
+	}
+
+	return
+}
+
+// validate checks value against the input type and condition and returns the normalized value.
+func (input Input) validate(value string) (string, error) {
+	switch input.Type {
+	case INPUT_BOOL:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a bool", value)
+		}
+		value = strconv.FormatBool(b)
+
+	case INPUT_CHOICE:
+		if !slices.Contains(input.Condition.Include, value) {
+			return "", fmt.Errorf("%q is not one of %s", value, strings.Join(input.Condition.Include, ", "))
+		}
+	}
+
+	ok, err := input.Condition.Check(input.Name, map[string]schema.Fact{input.Name: {value}}, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%q does not satisfy the input condition", value)
+	}
+
+	return value, nil
+}
+
+// applyInputs validates the inputs declared by the pipeline against the values passed in the trigger and injects them as env.
+// Pipelines with missing required inputs are skipped, pipelines with invalid inputs fail.
+func applyInputs(pipeline *paramPipeline, trigger schema.Trigger) bool {
+	keys := make([]string, 0, len(pipeline.When))
+	for key := range pipeline.When {
+		if strings.HasPrefix(key, INPUT_PREFIX) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		input, err := parseInput(key, pipeline.When[key])
+		if err != nil {
+			pipeline.Condition = key
+			pipeline.fail(err.Error())
+			return false
+		}
+
+		value, ok := trigger[INPUT_PREFIX+input.Name]
+		if ok {
+			value, err = input.validate(value)
+			if err != nil {
+				pipeline.Condition = key
+				pipeline.fail(fmt.Sprintf("invalid value for input %s - %s", input.Name, err))
+				return false
+			}
+		} else if input.HasDefault {
+			value = input.Default
+		} else {
+			pipeline.Condition = key
+			pipeline.skip(fmt.Sprintf("missing required input %s", input.Name))
+			return false
+		}
+
+		if _, exists := pipeline.Env[input.Name]; exists {
+			pipeline.Condition = key
+			pipeline.fail(fmt.Sprintf("input %s collides with env defined by the pipeline", input.Name))
+			return false
+		}
+
+		if pipeline.Env == nil {
+			pipeline.Env = make(map[string]schema.Env)
+		}
+		pipeline.Env[input.Name] = schema.Env{Value: value}
+		if !slices.Contains(pipeline.Params.Env, input.Name) {
+			pipeline.Params.Env = append(pipeline.Params.Env, input.Name)
+		}
+
+		if pipeline.Inputs == nil {
+			pipeline.Inputs = make(map[string]string)
+		}
+		pipeline.Inputs[input.Name] = value
+	}
+
+	return true
+}
+
+// checkInputCollisions fails the pipeline if one of its inputs has the same name as env resolved by a plugin.
+// Inputs are passed by whoever triggers the pipeline, so they must not replace env provided by plugins, e.g. secrets.
+func checkInputCollisions(pipeline *paramPipeline, resolved map[string]schema.Env) bool {
+	names := make([]string, 0, len(pipeline.Inputs))
+	for name := range pipeline.Inputs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if _, exists := resolved[name]; exists {
+			pipeline.fail(fmt.Sprintf("input %s collides with env provided by a plugin", name))
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,450 @@
+package triggers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/reeveci/reeve-lib/conditions"
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve-lib/vars"
+	"github.com/reeveci/reeve/reeve-common/tracing"
+	"github.com/reeveci/reeve/reeve-server/logging"
+	"github.com/reeveci/reeve/reeve-server/runtime"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Results of a pipeline decision.
+const (
+	RESULT_RUN      = "run"
+	RESULT_SKIPPED  = "skipped"
+	RESULT_FAILED   = "failed"
+	RESULT_APPROVAL = "approval"
+)
+
+var pipelineDefaultConditions = map[string]schema.Condition{
+	"workerGroup": {
+		Include: []string{schema.DEFAULT_WORKER_GROUP},
+	},
+}
+
+// Decision describes whether a discovered pipeline is run for a trigger and why.
+type Decision struct {
+	Pipeline string `json:"pipeline"`
+	Plugin   string `json:"plugin"`
+	Result   string `json:"result"`
+	Reason   string `json:"reason,omitempty"`
+	// Condition is the key of the first condition which is not satisfied.
+	Condition    string   `json:"condition,omitempty"`
+	MissingEnv   []string `json:"missingEnv,omitempty"`
+	WorkerGroups []string `json:"workerGroups,omitempty"`
+	// Inputs contains the values of input parameters declared by the pipeline.
+	Inputs map[string]string `json:"inputs,omitempty"`
+}
+
+// PluginError is an error returned by a plugin while discovering pipelines or resolving env.
+type PluginError struct {
+	Plugin string `json:"plugin"`
+	Stage  string `json:"stage"`
+	Error  string `json:"error"`
+}
+
+type paramPipeline struct {
+	Params vars.PipelineEnvBundle
+	Decision
+	schema.Pipeline
+	// SecretSources contains the resolve plugin of each secret env resolved by plugins.
+	SecretSources map[string]string
+}
+
+func (p *paramPipeline) skip(reason string) {
+	p.Result = RESULT_SKIPPED
+	p.Reason = reason
+}
+
+func (p *paramPipeline) fail(reason string) {
+	p.Result = RESULT_FAILED
+	p.Reason = reason
+}
+
+// Process discovers all pipelines for a trigger and enqueues those matching their conditions.
+// Triggers matching a trigger approval rule are parked instead, unless they have already been approved.
+func Process(ctx context.Context, runtime *runtime.Runtime, trigger schema.Trigger, approved bool) {
+	if !approved {
+		if rule := ApprovalRule(runtime, trigger); rule != nil {
+			park(ctx, runtime, trigger, rule)
+			return
+		}
+	}
+
+	pipelines, _ := evaluate(ctx, runtime, trigger)
+
+	enqueued := false
+
+	for _, pipeline := range pipelines {
+		switch pipeline.Result {
+		case RESULT_FAILED:
+			runtime.ErrorLog.Error("error running pipeline", logging.KEY_PIPELINE, pipeline.Name, logging.KEY_PLUGIN, pipeline.Plugin, logging.KEY_ERROR, pipeline.Reason)
+
+		case RESULT_RUN:
+			for _, group := range pipeline.WorkerGroups {
+				workerPipeline := pipeline.Pipeline
+				workerPipeline.Facts = make(map[string]schema.Fact, len(pipeline.Facts))
+				for key, value := range pipeline.Facts {
+					workerPipeline.Facts[key] = value
+				}
+				workerPipeline.Facts["workerGroup"] = schema.Fact{group}
+
+				if runtime.WorkerQueues[group].Full() {
+					runtime.ErrorLog.Error("dropping pipeline - worker queue is full", logging.KEY_PIPELINE, pipeline.Name, logging.KEY_WORKER_GROUP, group)
+					continue
+				}
+
+				activity := runtime.Activity[group].RegisterPipeline(ctx, workerPipeline)
+				activity.SecretSources = pipeline.SecretSources
+				runtime.WorkerQueues[group].Push(activity)
+			}
+			enqueued = true
+		}
+	}
+
+	if enqueued {
+		runtime.LogQueueStatus()
+	}
+}
+
+// evaluate discovers all pipelines for a trigger, resolves their env and checks their conditions.
+// Pipelines which should be run have their env merged, a decision is recorded for each pipeline.
+func evaluate(ctx context.Context, runtime *runtime.Runtime, trigger schema.Trigger) (pipelines []*paramPipeline, pluginErrors []PluginError) {
+	pipelines, pluginErrors = discoverPipelines(ctx, runtime, trigger)
+	if len(pipelines) == 0 {
+		return
+	}
+
+	pipelineEnvMap := make(map[string]bool)
+	for _, pipeline := range pipelines {
+		for _, key := range pipeline.Params.PipelineEnv {
+			pipelineEnvMap[key] = true
+		}
+	}
+	resolvedPipelineEnv, pipelineSources, resolveErrors := resolveEnv(ctx, runtime, pipelineEnvMap)
+	pluginErrors = append(pluginErrors, resolveErrors...)
+
+	remainingPipelines := make([]*paramPipeline, 0, len(pipelines))
+
+	for _, pipeline := range pipelines {
+		if checkPipeline(ctx, runtime, trigger, pipeline, resolvedPipelineEnv) {
+			remainingPipelines = append(remainingPipelines, pipeline)
+		}
+	}
+	if len(remainingPipelines) == 0 {
+		return
+	}
+
+	remainingEnvMap := make(map[string]bool)
+	for _, pipeline := range remainingPipelines {
+		for _, key := range pipeline.Params.RemainingEnv {
+			remainingEnvMap[key] = true
+		}
+	}
+	resolvedRemainingEnv, remainingSources, resolveErrors := resolveEnv(ctx, runtime, remainingEnvMap)
+	pluginErrors = append(pluginErrors, resolveErrors...)
+
+	for _, pipeline := range remainingPipelines {
+		if !checkInputCollisions(pipeline, resolvedRemainingEnv) {
+			continue
+		}
+
+		env, err := vars.MergeEnv(pipeline.Params.Env, pipeline.Env, resolvedPipelineEnv, resolvedRemainingEnv)
+		if err != nil {
+			pipeline.MissingEnv = missingEnv(pipeline.Params.Env, pipeline.Env, resolvedPipelineEnv, resolvedRemainingEnv)
+			pipeline.fail(err.Error())
+			continue
+		}
+		pipeline.Env = env
+		pipeline.SecretSources = secretSources(env, []map[string]schema.Env{resolvedPipelineEnv, resolvedRemainingEnv}, []map[string]string{pipelineSources, remainingSources})
+		pipeline.Result = RESULT_RUN
+	}
+
+	return
+}
+
+// checkPipeline checks the pipeline conditions and determines the worker groups the pipeline should run in.
+func checkPipeline(ctx context.Context, runtime *runtime.Runtime, trigger schema.Trigger, pipeline *paramPipeline, resolvedPipelineEnv map[string]schema.Env) (matched bool) {
+	_, span := tracing.Start(ctx, "check conditions", tracing.ATTR_PIPELINE.String(pipeline.Name))
+	var spanErr error
+	defer func() {
+		span.SetAttributes(attribute.Bool("reeve.matched", matched), attribute.StringSlice("reeve.worker_groups", pipeline.WorkerGroups))
+		if pipeline.Condition != "" {
+			span.SetAttributes(attribute.String("reeve.condition", pipeline.Condition))
+		}
+		tracing.End(span, spanErr)
+	}()
+
+	if !applyInputs(pipeline, trigger) {
+		if pipeline.Result == RESULT_FAILED {
+			spanErr = errors.New(pipeline.Reason)
+		}
+		return false
+	}
+	if !checkInputCollisions(pipeline, resolvedPipelineEnv) {
+		spanErr = errors.New(pipeline.Reason)
+		return false
+	}
+
+	pipelineEnv, err := vars.MergeEnv(pipeline.Params.PipelineEnv, pipeline.Env, resolvedPipelineEnv)
+	if err != nil {
+		spanErr = err
+		pipeline.MissingEnv = missingEnv(pipeline.Params.PipelineEnv, pipeline.Env, resolvedPipelineEnv)
+		pipeline.fail(fmt.Sprintf("error analyzing pipeline - %s", err))
+		return false
+	}
+
+	conditions.ApplyDefaults(&pipeline.When, pipelineDefaultConditions)
+
+	pipelineWhen := make(map[string]schema.Condition, len(pipeline.When))
+	workerWhen := make(map[string]schema.Condition, 1)
+	for key, value := range pipeline.When {
+		switch key {
+		case "workerGroup":
+			workerWhen[key] = value
+		default:
+			if strings.HasPrefix(key, INPUT_PREFIX) {
+				// inputs have already been validated
+				continue
+			}
+			pipelineWhen[key] = value
+		}
+	}
+
+	// conditions are checked one by one, so that the first condition which is not satisfied can be reported
+	keys := make([]string, 0, len(pipelineWhen))
+	for key := range pipelineWhen {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		ok, err := pipelineWhen[key].Check(key, pipeline.Facts, pipelineEnv, nil)
+		if err != nil {
+			spanErr = err
+			pipeline.Condition = key
+			pipeline.fail(fmt.Sprintf("checking condition %s failed - %s", key, err))
+			return false
+		}
+		if !ok {
+			pipeline.Condition = key
+			pipeline.skip(fmt.Sprintf("condition %s is not satisfied", key))
+			return false
+		}
+	}
+
+	workerGroups := make([]string, 0, len(runtime.WorkerGroups))
+	for group := range runtime.WorkerGroups {
+		ok, err := conditions.Check(map[string]schema.Fact{
+			"workerGroup": {group},
+		}, workerWhen, pipelineEnv, nil)
+		if err != nil {
+			spanErr = err
+			pipeline.Condition = "workerGroup"
+			pipeline.fail(fmt.Sprintf("checking condition workerGroup failed - %s", err))
+			return false
+		}
+		if ok {
+			workerGroups = append(workerGroups, group)
+		}
+	}
+	sort.Strings(workerGroups)
+	pipeline.WorkerGroups = workerGroups
+
+	if len(pipeline.WorkerGroups) == 0 {
+		pipeline.Condition = "workerGroup"
+		pipeline.skip("no worker group matches")
+		return false
+	}
+
+	return true
+}
+
+// missingEnv returns the keys which are not contained in any of envs.
+func missingEnv(keys []string, envs ...map[string]schema.Env) []string {
+	var missing []string
+	for _, key := range keys {
+		found := false
+		for _, env := range envs {
+			if _, ok := env[key]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func discoverPipelines(ctx context.Context, runtime *runtime.Runtime, trigger schema.Trigger) (result []*paramPipeline, pluginErrors []PluginError) {
+	ctx, span := tracing.Start(ctx, "discover")
+	defer func() {
+		span.SetAttributes(attribute.Int("reeve.pipelines", len(result)))
+		span.End()
+	}()
+
+	pluginCount := len(runtime.PluginProvider.DiscoverPlugins)
+
+	if pluginCount > 0 {
+		type discoverResult struct {
+			pipelines []paramPipeline
+			err       *PluginError
+		}
+		channel := make(chan discoverResult, pluginCount)
+
+		for k, v := range runtime.PluginProvider.DiscoverPlugins {
+			pluginName := k
+			plugin := v
+
+			go func() {
+				_, pluginSpan := tracing.Start(ctx, "plugin discover", tracing.ATTR_PLUGIN.String(pluginName))
+				pipelines, err := plugin.Discover(trigger)
+				tracing.End(pluginSpan, err)
+				if err != nil {
+					runtime.ErrorLog.Error("discovering pipelines failed", logging.KEY_PLUGIN, pluginName, logging.Err(err))
+					channel <- discoverResult{err: &PluginError{Plugin: pluginName, Stage: "discover", Error: err.Error()}}
+					return
+				}
+
+				toBeRun := make([]paramPipeline, 0, len(pipelines))
+				for _, pipeline := range pipelines {
+					if pipeline.Name == "" || len(pipeline.Steps) == 0 {
+						runtime.ErrorLog.Warn("skipping empty pipeline", logging.KEY_PLUGIN, pluginName, logging.KEY_PIPELINE, pipeline.Name)
+						continue
+					}
+
+					toBeRun = append(toBeRun, paramPipeline{
+						Params:   vars.FindAllEnv(pipeline),
+						Decision: Decision{Pipeline: pipeline.Name, Plugin: pluginName},
+						Pipeline: pipeline,
+					})
+				}
+
+				channel <- discoverResult{pipelines: toBeRun}
+			}()
+		}
+
+		for i := 0; i < pluginCount; i++ {
+			discovered := <-channel
+			if discovered.err != nil {
+				pluginErrors = append(pluginErrors, *discovered.err)
+			}
+
+			for _, p := range discovered.pipelines {
+				pipeline := p
+				result = append(result, &pipeline)
+			}
+		}
+	}
+
+	return
+}
+
+// secretSources returns the resolve plugin of each secret env which has been taken from the resolved env.
+func secretSources(env map[string]schema.Env, resolved []map[string]schema.Env, sources []map[string]string) map[string]string {
+	result := make(map[string]string)
+	for key, value := range env {
+		if !value.Secret {
+			continue
+		}
+		for i, resolvedEnv := range resolved {
+			if resolvedValue, ok := resolvedEnv[key]; ok && resolvedValue == value {
+				result[key] = sources[i][key]
+				break
+			}
+		}
+	}
+	return result
+}
+
+// ResolvePluginEnv resolves the given env keys using a single resolve plugin.
+func ResolvePluginEnv(ctx context.Context, runtime *runtime.Runtime, pluginName string, keys []string) (map[string]schema.Env, error) {
+	plugin, ok := runtime.PluginProvider.ResolvePlugins[pluginName]
+	if !ok {
+		return nil, fmt.Errorf("resolve plugin %s is not loaded", pluginName)
+	}
+
+	_, span := tracing.Start(ctx, "plugin resolve", tracing.ATTR_PLUGIN.String(pluginName))
+	env, err := plugin.Resolve(keys)
+	tracing.End(span, err)
+	return env, err
+}
+
+// resolveEnv resolves the given env keys using all resolve plugins, sources contains the plugin each key has been taken from.
+func resolveEnv(ctx context.Context, runtime *runtime.Runtime, envMap map[string]bool) (result map[string]schema.Env, sources map[string]string, pluginErrors []PluginError) {
+	ctx, span := tracing.Start(ctx, "resolve env")
+	defer func() {
+		span.SetAttributes(attribute.Int("reeve.resolved", len(result)))
+		span.End()
+	}()
+
+	pluginCount := len(runtime.PluginProvider.ResolvePlugins)
+
+	env := make([]string, 0, len(envMap))
+	for key, ok := range envMap {
+		if key != "" && ok {
+			env = append(env, key)
+		}
+	}
+
+	result = make(map[string]schema.Env)
+	sources = make(map[string]string)
+
+	if len(env) > 0 && pluginCount > 0 {
+		type resolveResult struct {
+			plugin string
+			env    map[string]schema.Env
+			err    *PluginError
+		}
+		channel := make(chan resolveResult, pluginCount)
+
+		for k, v := range runtime.PluginProvider.ResolvePlugins {
+			pluginName := k
+			plugin := v
+
+			go func() {
+				_, pluginSpan := tracing.Start(ctx, "plugin resolve", tracing.ATTR_PLUGIN.String(pluginName))
+				env, err := plugin.Resolve(env)
+				tracing.End(pluginSpan, err)
+				if err != nil {
+					runtime.ErrorLog.Error("resolving environment variables failed", logging.KEY_PLUGIN, pluginName, logging.Err(err))
+					channel <- resolveResult{err: &PluginError{Plugin: pluginName, Stage: "resolve", Error: err.Error()}}
+					return
+				}
+
+				channel <- resolveResult{plugin: pluginName, env: env}
+			}()
+		}
+
+		for i := 0; i < pluginCount; i++ {
+			resolved := <-channel
+			if resolved.err != nil {
+				pluginErrors = append(pluginErrors, *resolved.err)
+			}
+
+			for key, value := range resolved.env {
+				if key != "" {
+					if existing, ok := result[key]; !ok || value.Priority < existing.Priority {
+						result[key] = value
+						sources[key] = resolved.plugin
+					}
+				}
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(publishArtifactCmd)
+}
+
+var publishArtifactCmd = &cobra.Command{
+	Use:                   "publish-artifact name path...",
+	Short:                 "Publish files and directories as gzipped tar archive, which can be downloaded from the server",
+	DisableFlagsInUseLine: true,
+
+	Args: cobra.MinimumNArgs(2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		api := os.Getenv("REEVE_API")
+		if api == "" {
+			fmt.Fprintln(os.Stderr, "Error publishing artifact - missing REEVE_API environment variable")
+			os.Exit(1)
+		}
+
+		reader, writer := io.Pipe()
+		go func() {
+			writer.CloseWithError(writeArchive(writer, args[1:]))
+		}()
+
+		fmt.Printf("Publishing artifact %s\n", name)
+
+		resp, err := http.Post(fmt.Sprintf("%s/api/v1/artifact?%s", strings.TrimSuffix(api, "/"), url.Values{"name": {name}}.Encode()), "application/gzip", reader)
+		reader.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error publishing artifact %s - %s\n", name, err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			message, _ := io.ReadAll(resp.Body)
+			fmt.Fprintf(os.Stderr, "Error publishing artifact %s - status %v - %s\n", name, resp.StatusCode, strings.TrimSpace(string(message)))
+			os.Exit(1)
+		}
+	},
+}
+
+// writeArchive writes all files in paths to w as gzipped tar archive.
+// Paths are stored relative to the working directory, absolute paths without their leading slash.
+func writeArchive(w io.Writer, paths []string) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+
+			var link string
+			if info.Mode()&fs.ModeSymlink != 0 {
+				link, err = os.Readlink(path)
+				if err != nil {
+					return err
+				}
+			}
+
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "/")
+			if entry.IsDir() {
+				header.Name += "/"
+			}
+
+			err = tarWriter.WriteHeader(header)
+			if err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			_, err = io.Copy(tarWriter, file)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error archiving %s - %s", root, err)
+		}
+	}
+
+	err := tarWriter.Close()
+	if err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
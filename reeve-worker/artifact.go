@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/reeveci/reeve/reeve-client"
+	"github.com/reeveci/reeve/reeve-common/tracing"
+)
+
+const CONTROL_ARTIFACT = "artifact"
+
+// ArtifactChunk is a part of an artifact published by the runner.
+// Chunks with the same ID belong to the same artifact, the last chunk is marked as done or aborted.
+// Chunks are numbered consecutively starting at 0, artifacts with missing chunks are discarded.
+type ArtifactChunk struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Seq     int    `json:"seq"`
+	Data    []byte `json:"data,omitempty"`
+	Done    bool   `json:"done,omitempty"`
+	Aborted bool   `json:"aborted,omitempty"`
+}
+
+// ArtifactBuffers collect the chunks of artifacts in temporary files until they are complete.
+type ArtifactBuffers map[string]*artifactBuffer
+
+type artifactBuffer struct {
+	file *os.File
+	next int
+	// failed is set once a chunk is missing, the remaining chunks of the artifact are ignored
+	failed bool
+}
+
+// Add appends a chunk to its artifact and returns the path of the temporary file once the artifact is complete.
+// The caller must remove the returned file.
+func (b ArtifactBuffers) Add(chunk ArtifactChunk) (string, error) {
+	buffer, ok := b[chunk.ID]
+	if !ok {
+		buffer = &artifactBuffer{}
+		b[chunk.ID] = buffer
+	}
+
+	if buffer.failed {
+		if chunk.Done || chunk.Aborted {
+			delete(b, chunk.ID)
+		}
+		return "", nil
+	}
+
+	if chunk.Seq != buffer.next {
+		b.remove(chunk.ID)
+		if !chunk.Done && !chunk.Aborted {
+			b[chunk.ID] = &artifactBuffer{failed: true}
+		}
+		return "", fmt.Errorf("discarding artifact %s - expected chunk %v but received chunk %v", chunk.Name, buffer.next, chunk.Seq)
+	}
+	buffer.next++
+
+	if chunk.Aborted {
+		b.remove(chunk.ID)
+		return "", fmt.Errorf("runner aborted sending artifact %s", chunk.Name)
+	}
+
+	if buffer.file == nil {
+		var err error
+		buffer.file, err = os.CreateTemp("", "reeve-artifact-*")
+		if err != nil {
+			b.remove(chunk.ID)
+			return "", fmt.Errorf("error creating artifact buffer - %s", err)
+		}
+	}
+
+	if len(chunk.Data) > 0 {
+		_, err := buffer.file.Write(chunk.Data)
+		if err != nil {
+			b.remove(chunk.ID)
+			return "", fmt.Errorf("error buffering artifact %s - %s", chunk.Name, err)
+		}
+	}
+
+	if !chunk.Done {
+		return "", nil
+	}
+
+	delete(b, chunk.ID)
+	err := buffer.file.Close()
+	if err != nil {
+		os.Remove(buffer.file.Name())
+		return "", fmt.Errorf("error buffering artifact %s - %s", chunk.Name, err)
+	}
+	return buffer.file.Name(), nil
+}
+
+// Close removes all incomplete artifacts.
+func (b ArtifactBuffers) Close() {
+	for id := range b {
+		b.remove(id)
+	}
+}
+
+func (b ArtifactBuffers) remove(id string) {
+	if buffer, ok := b[id]; ok {
+		if buffer.file != nil {
+			buffer.file.Close()
+			os.Remove(buffer.file.Name())
+		}
+		delete(b, id)
+	}
+}
+
+// UploadArtifact uploads a complete artifact to the server, retrying on connection and server errors.
+// The file is removed afterwards.
+func UploadArtifact(ctx context.Context, api *client.Client, workerGroup, activity, name, path string, procLog, errorLog *log.Logger) {
+	defer os.Remove(path)
+
+	ctx, span := tracing.Start(ctx, "upload artifact")
+
+	for {
+		err := uploadArtifactFile(ctx, api, workerGroup, activity, name, path)
+		if err == nil {
+			procLog.Printf("uploaded artifact %s\n", name)
+			tracing.End(span, nil)
+			return
+		}
+
+		if !retryable(err) || ctx.Err() != nil {
+			err = fmt.Errorf("uploading artifact %s failed - %s", name, err)
+			errorLog.Println(err)
+			tracing.End(span, err)
+			return
+		}
+
+		errorLog.Printf("uploading artifact %s failed, retrying in %v - %s\n", name, retry, err)
+
+		if !waitRetry(ctx) {
+			tracing.End(span, ctx.Err())
+			return
+		}
+	}
+}
+
+func uploadArtifactFile(ctx context.Context, api *client.Client, workerGroup, activity, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening artifact buffer - %s", err)
+	}
+	defer file.Close()
+
+	_, err = api.WorkerArtifact(ctx, workerGroup, activity, name, file)
+	return err
+}
@@ -1,22 +1,40 @@
 #!/bin/sh
 set -e
 
-docker run \
+exec docker run \
   --rm -i \
   -v /var/run/docker.sock:/var/run/docker.sock \
   -e DOCKER_LOGIN_REGISTRIES \
   -e DOCKER_LOGIN_REGISTRY \
   -e DOCKER_LOGIN_USER \
   -e DOCKER_LOGIN_PASSWORD \
+  -e REEVE_ACTIVITY \
+  -e REEVE_WORKER_ID \
   -e REEVE_API_PORT \
   -e REEVE_RUNTIME_ENV \
+  -e REEVE_ALLOWED_RUNTIME_ENVS \
   -e REEVE_DOCKER_COMMAND \
   -e REEVE_FORWARD_PROXY \
   -e REEVE_NO_DESCRIPTION \
+  -e REEVE_CACHE_VOLUME \
+  -e REEVE_CACHE_MAX_SIZE \
+  -e REEVE_CACHE_IMAGE \
+  -e REEVE_PARALLELISM \
+  -e REEVE_PIPELINE_TIMEOUT \
+  -e REEVE_STOP_TIMEOUT \
+  -e REEVE_TASK_CPUS \
+  -e REEVE_TASK_MEMORY \
+  -e REEVE_TASK_PIDS_LIMIT \
+  -e REEVE_TRUSTED_SECURITY \
+  -e REEVE_UNTRUSTED_SECURITY \
+  -e REEVE_TRACING_EXPORTER \
+  -e REEVE_TRACING_ENDPOINT \
+  -e TRACEPARENT -e TRACESTATE \
   -e HTTP_PROXY -e http_proxy \
   -e HTTPS_PROXY -e https_proxy \
   -e FTP_PROXY -e ftp_proxy \
   -e NO_PROXY -e no_proxy \
   -e ALL_PROXY -e all_proxy \
+  --label reeve.runner.activity="$REEVE_ACTIVITY" \
   --name reeve-runner-$(cat /proc/sys/kernel/random/uuid) \
   $REEVE_RUNNER_IMAGE
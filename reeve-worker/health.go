@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/reeveci/reeve/reeve-common/healthcheck"
+)
+
+// Worker states reported by the health endpoints.
+const (
+	STATE_CONNECTING   = "connecting"
+	STATE_WAITING      = "waiting"
+	STATE_RUNNING      = "running"
+	STATE_RECONNECTING = "reconnecting"
+)
+
+// Health tracks the state of the worker loop.
+type Health struct {
+	lock     sync.Mutex
+	state    string
+	since    time.Time
+	activity string
+	// failures counts failed requests since the last pipeline was received
+	failures int
+}
+
+type healthResponse struct {
+	State    string    `json:"state"`
+	Since    time.Time `json:"since"`
+	Activity string    `json:"activity,omitempty"`
+	Failures int       `json:"failures,omitempty"`
+}
+
+func NewHealth() *Health {
+	return &Health{state: STATE_CONNECTING, since: time.Now()}
+}
+
+func (h *Health) set(state, activity string) {
+	if h.state != state {
+		h.since = time.Now()
+	}
+	h.state = state
+	h.activity = activity
+}
+
+// Waiting marks the worker as connected to the server and waiting for a pipeline.
+func (h *Health) Waiting() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.set(STATE_WAITING, "")
+}
+
+// Running marks the worker as executing the given activity.
+func (h *Health) Running(activity string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.set(STATE_RUNNING, activity)
+	h.failures = 0
+}
+
+// Failed records a failed request to the server, the worker reconnects afterwards.
+func (h *Health) Failed() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.set(STATE_RECONNECTING, "")
+	h.failures += 1
+}
+
+// Activity returns the activity executed by the worker, which is empty unless a pipeline is running.
+func (h *Health) Activity() string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.state != STATE_RUNNING {
+		return ""
+	}
+	return h.activity
+}
+
+func (h *Health) report() healthResponse {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return healthResponse{
+		State:    h.state,
+		Since:    h.since,
+		Activity: h.activity,
+		Failures: h.failures,
+	}
+}
+
+// ServeHealth serves /healthz, which succeeds as long as the worker is responsive,
+// and /readyz, which fails while the worker is unable to reach the server.
+func ServeHealth(health *Health, port string, procLog, errorLog *log.Logger) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(res http.ResponseWriter, req *http.Request) {
+		healthcheck.Write(res, health.report(), true)
+	})
+	mux.HandleFunc("/readyz", func(res http.ResponseWriter, req *http.Request) {
+		report := health.report()
+		healthcheck.Write(res, report, report.State == STATE_WAITING || report.State == STATE_RUNNING)
+	})
+
+	procLog.Printf("serving health checks at http://localhost:%s\n", port)
+	err := http.ListenAndServe(fmt.Sprintf(":%s", port), mux)
+	errorLog.Printf("health server exited - %s\n", err)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// LABEL_ACTIVITY is set by the runner on all containers, networks and volumes it creates, containing the activity ID.
+const LABEL_ACTIVITY = "reeve.activity"
+
+// LABEL_WORKER is set by the runner on all containers, networks and volumes it creates, containing the ID of the worker which started it.
+const LABEL_WORKER = "reeve.worker"
+
+// LABEL_RUNNER_ACTIVITY is set on runner containers started by docker-runner.sh, containing the activity ID.
+const LABEL_RUNNER_ACTIVITY = "reeve.runner.activity"
+
+// Janitor removes containers, networks and volumes left behind by runners which have been killed before cleaning up.
+// Only resources labeled with the ID of this worker are considered, since runners of other workers cannot be detected.
+// Resources are owned by a runner as long as the worker is executing their activity or a runner container of their activity is running.
+type Janitor struct {
+	// Command is the container CLI used by the runner, e.g. docker or podman.
+	Command string
+	// WorkerID is the ID of this worker, see LABEL_WORKER.
+	WorkerID string
+
+	health            *Health
+	procLog, errorLog *log.Logger
+}
+
+// janitorResource describes a kind of resource labeled by the runner.
+type janitorResource struct {
+	name string
+	// list returns the IDs of the labeled resources
+	list []string
+	// inspect formats the ID and the activity label of a resource
+	inspect []string
+	remove  []string
+}
+
+var janitorResources = []janitorResource{
+	{
+		name:    "container",
+		list:    []string{"ps", "-a", "-q", "--no-trunc", "--filter", "label=" + LABEL_ACTIVITY},
+		inspect: []string{"inspect", "--format", `{{.Id}} {{index .Config.Labels "` + LABEL_ACTIVITY + `"}}`},
+		remove:  []string{"rm", "-f"},
+	},
+	{
+		name:    "network",
+		list:    []string{"network", "ls", "-q", "--no-trunc", "--filter", "label=" + LABEL_ACTIVITY},
+		inspect: []string{"network", "inspect", "--format", `{{.Id}} {{index .Labels "` + LABEL_ACTIVITY + `"}}`},
+		remove:  []string{"network", "rm"},
+	},
+	{
+		name:    "volume",
+		list:    []string{"volume", "ls", "-q", "--filter", "label=" + LABEL_ACTIVITY},
+		inspect: []string{"volume", "inspect", "--format", `{{.Name}} {{index .Labels "` + LABEL_ACTIVITY + `"}}`},
+		remove:  []string{"volume", "rm", "-f"},
+	},
+}
+
+func NewJanitor(command, workerID string, health *Health, procLog, errorLog *log.Logger) *Janitor {
+	return &Janitor{Command: command, WorkerID: workerID, health: health, procLog: procLog, errorLog: errorLog}
+}
+
+// Start cleans up immediately and then once per interval.
+func (j *Janitor) Start(interval time.Duration) {
+	go func() {
+		for {
+			j.Clean()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Clean removes all labeled resources of this worker whose runner is gone.
+// Containers are removed first, so that networks and volumes are no longer in use.
+func (j *Janitor) Clean() {
+	owned := make(map[string]bool)
+
+	for _, resource := range janitorResources {
+		activities, err := j.list(resource)
+		if err != nil {
+			j.errorLog.Printf("janitor: listing %ss failed - %s\n", resource.name, err)
+			continue
+		}
+
+		for id, activity := range activities {
+			isOwned, ok := owned[activity]
+			if !ok {
+				isOwned, err = j.owned(activity)
+				if err != nil {
+					j.errorLog.Printf("janitor: checking runner of activity %s failed - %s\n", activity, err)
+					continue
+				}
+				owned[activity] = isOwned
+			}
+			if isOwned {
+				continue
+			}
+
+			_, err := j.run(slices.Concat(resource.remove, []string{id})...)
+			if err != nil {
+				j.errorLog.Printf("janitor: removing %s %s of activity %s failed - %s\n", resource.name, id, activity, err)
+				continue
+			}
+			j.procLog.Printf("janitor: removed %s %s of activity %s\n", resource.name, id, activity)
+		}
+	}
+}
+
+// list returns the activities of the labeled resources of a kind by resource ID.
+func (j *Janitor) list(resource janitorResource) (map[string]string, error) {
+	output, err := j.run(slices.Concat(resource.list, []string{"--filter", fmt.Sprintf("label=%s=%s", LABEL_WORKER, j.WorkerID)})...)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := strings.Fields(output)
+	activities := make(map[string]string, len(ids))
+	for _, id := range ids {
+		// resources are inspected one by one, since they may be removed in the meantime
+		output, err := j.run(slices.Concat(resource.inspect, []string{id})...)
+		if err != nil {
+			continue
+		}
+
+		id, activity, _ := strings.Cut(strings.TrimSpace(output), " ")
+		if activity != "" {
+			activities[id] = activity
+		}
+	}
+	return activities, nil
+}
+
+// owned returns true if the runner of an activity is still running.
+func (j *Janitor) owned(activity string) (bool, error) {
+	if activity == j.health.Activity() {
+		return true, nil
+	}
+
+	output, err := j.run("ps", "-q", "--filter", fmt.Sprintf("label=%s=%s", LABEL_RUNNER_ACTIVITY, activity), "--filter", "status=running")
+	if err != nil {
+		return false, err
+	}
+	return output != "", nil
+}
+
+func (j *Janitor) run(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(j.Command, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%s - %s", err, message)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -1,8 +1,7 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -11,26 +10,25 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/djherbis/stream"
 	"github.com/reeveci/reeve-lib/exe"
 	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve/reeve-client"
+	"github.com/reeveci/reeve/reeve-common/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var buildVersion = "development"
 
 const retry = 5 * time.Second
 
-type workerQueueResponse struct {
-	Contract string          `json:"contract"`
-	Activity string          `json:"activity"`
-	Pipeline json.RawMessage `json:"pipeline"`
-}
-
 func main() {
 	var version bool
 
@@ -64,105 +62,114 @@ func main() {
 	}
 
 	workerGroup := exe.GetEnvDef("REEVE_WORKER_GROUP", schema.DEFAULT_WORKER_GROUP)
+
+	// The worker ID is passed to the runner, which labels its resources, so that the janitor only removes resources of this worker
+	workerID := exe.GetEnvDef("REEVE_WORKER_ID", "")
+	if workerID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			procErrLog.Fatalf("error resolving hostname - %s\n", err)
+			return
+		}
+		workerID = hostname
+	}
+
 	runnerCommand := exe.GetEnvDef("REEVE_RUNNER_COMMAND", "reeve-runner")
 
 	authHeader := exe.GetEnvDef("REEVE_WORKER_AUTH_HEADER", "Authorization")
 	authPrefix := exe.GetEnvDef("REEVE_WORKER_AUTH_PREFIX", "Bearer ")
 	auth := strings.TrimSpace(authPrefix + workerSecret)
-	client := &http.Client{}
 
-	for {
-		procLog.Printf("connecting to %s", apiUrl)
-
-		// Get message from worker queue
-		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v1/worker/queue?group=%s", apiUrl, workerGroup), nil)
-		if err != nil {
-			procErrLog.Printf("creating HTTP request failed - %s\n", err)
-			procLog.Printf("reconnecting in %v\n", retry)
-			time.Sleep(retry)
-			continue
+	api := client.New(apiUrl,
+		client.WithAuth(authHeader, auth),
+		client.WithRequestEditor(func(ctx context.Context, req *http.Request) error {
+			tracing.Inject(ctx, req.Header)
+			return nil
+		}),
+	)
+
+	shutdownTracing, err := tracing.Setup("reeve-worker", exe.GetEnvDef("REEVE_TRACING_EXPORTER", tracing.EXPORTER_NONE), exe.GetEnvDef("REEVE_TRACING_ENDPOINT", ""), exe.GetEnvDef("REEVE_TRACING_FILE", ""), buildVersion)
+	if err != nil {
+		procErrLog.Fatalf("error setting up tracing - %s\n", err)
+		return
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			procErrLog.Printf("error flushing traces - %s\n", err)
 		}
+	}()
 
-		req.Header.Set(authHeader, auth)
+	health := NewHealth()
+	if healthPort := exe.GetEnvDef("REEVE_WORKER_HEALTH_PORT", ""); healthPort != "" {
+		go ServeHealth(health, healthPort, procLog, procErrLog)
+	}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			procErrLog.Printf("fetching message from queue failed - %s\n", err)
-			procLog.Printf("reconnecting in %v\n", retry)
-			time.Sleep(retry)
-			continue
+	// Remove resources left behind by killed runners, runners of the local runtime environment do not create any
+	janitorInterval, err := time.ParseDuration(exe.GetEnvDef("REEVE_JANITOR_INTERVAL", "10m"))
+	if err != nil || janitorInterval < 0 {
+		procErrLog.Fatalln("invalid REEVE_JANITOR_INTERVAL - must be a duration")
+		return
+	}
+	if janitorInterval > 0 {
+		// pipelines may select any runtime environment allowed by the worker, each container CLI is cleaned up separately
+		commands := make(map[string]bool)
+		for _, runtimeEnv := range append(strings.Fields(exe.GetEnvDef("REEVE_ALLOWED_RUNTIME_ENVS", "")), exe.GetEnvDef("REEVE_RUNTIME_ENV", "host")) {
+			switch runtimeEnv {
+			case "local":
+			case "podman":
+				commands[exe.GetEnvDef("REEVE_PODMAN_COMMAND", "podman")] = true
+			default:
+				commands[exe.GetEnvDef("REEVE_DOCKER_COMMAND", "docker")] = true
+			}
 		}
-
-		if resp.StatusCode != http.StatusOK {
-			errorMessage, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			procErrLog.Printf("fetching message from queue failed - status %v - %s\n", resp.StatusCode, string(errorMessage))
-			procLog.Printf("reconnecting in %v\n", retry)
-			time.Sleep(retry)
-			continue
+		for command := range commands {
+			NewJanitor(command, workerID, health, procLog, procErrLog).Start(janitorInterval)
 		}
+	}
 
-		if resp.Header.Get("Content-Type") != "application/json" {
-			resp.Body.Close()
-			procErrLog.Println("fetching message from queue failed - Content-Type header is not application/json")
-			procLog.Printf("reconnecting in %v\n", retry)
-			time.Sleep(retry)
-			continue
-		}
+	// Running pipelines are canceled on shutdown, their result is still reported
+	shutdown, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		var message workerQueueResponse
-		err = json.NewDecoder(resp.Body).Decode(&message)
-		resp.Body.Close()
-		if err != nil {
-			procErrLog.Printf("received invalid message from queue - %s\n", err)
-			procLog.Printf("reconnecting in %v\n", retry)
-			time.Sleep(retry)
-			continue
+	for {
+		if shutdown.Err() != nil {
+			procLog.Println("shutting down")
+			return
 		}
 
-		// Send acknowledgement
-		buffer := new(bytes.Buffer)
-		err = json.NewEncoder(buffer).Encode(schema.WorkerAckRequest{
-			Contract: message.Contract,
-		})
-		if err != nil {
-			procErrLog.Printf("encoding acknowledgement failed - %s\n", err)
-			procLog.Printf("reconnecting in %v\n", retry)
-			time.Sleep(retry)
-			continue
-		}
+		procLog.Printf("connecting to %s", apiUrl)
 
-		req, err = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/v1/worker/ack?group=%s", apiUrl, workerGroup), buffer)
+		// Get message from worker queue
+		health.Waiting()
+		message, err := api.WorkerQueue(shutdown, workerGroup)
+		if shutdown.Err() != nil {
+			procLog.Println("shutting down")
+			return
+		}
 		if err != nil {
-			procErrLog.Printf("creating HTTP request failed - %s\n", err)
+			procErrLog.Printf("fetching message from queue failed - %s\n", err)
 			procLog.Printf("reconnecting in %v\n", retry)
+			health.Failed()
 			time.Sleep(retry)
 			continue
 		}
 
-		req.Header.Set(authHeader, auth)
-		req.Header.Set("Content-Type", "application/json")
+		// Continue the trace of the activity, so that the execution appears as part of the pipeline
+		ctx, span := tracing.Start(tracing.Extract(message.Header), "worker execute", tracing.ATTR_WORKER_GROUP.String(workerGroup), tracing.ATTR_ACTIVITY.String(message.Activity))
 
-		resp, err = client.Do(req)
+		// Send acknowledgement
+		err = api.WorkerAck(ctx, workerGroup, message.Contract)
 		if err != nil {
 			procErrLog.Printf("sending acknowledgement failed - %s\n", err)
 			procLog.Printf("reconnecting in %v\n", retry)
+			health.Failed()
+			tracing.End(span, err)
 			time.Sleep(retry)
 			continue
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			errorMessage, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			procErrLog.Printf("sending acknowledgement failed - status %v - %s\n", resp.StatusCode, string(errorMessage))
-			procLog.Printf("reconnecting in %v\n", retry)
-			time.Sleep(retry)
-			continue
-		}
-
-		resp.Body.Close()
-
 		procLog.Println("starting pipeline execution")
+		health.Running(message.Activity)
 
 		// Create runner output stream
 		stream := stream.NewMemStream()
@@ -191,16 +198,13 @@ func main() {
 		go func() {
 			defer wg.Done()
 
-			SendLogs(stream, client, authHeader, auth, apiUrl, workerGroup, message.Activity, procLog, procErrLog)
+			SendLogs(ctx, stream, api, workerGroup, message.Activity, procLog, procErrLog)
 		}()
 
 		// Execute pipeline runner
-		cmd := exec.Command(runnerCommand)
-		cmd.Stdin = bytes.NewReader(message.Pipeline)
-		cmd.Stdout = stream
-		cmd.Stderr = stream
-		cmd.Env = os.Environ()
-		err = cmd.Run()
+		runCtx, runSpan := tracing.Start(ctx, "run pipeline")
+		err = RunPipeline(runCtx, shutdown, runnerCommand, message.Pipeline, stream, api, workerGroup, workerID, message.Activity, procLog, procErrLog)
+		tracing.End(runSpan, err)
 
 		stream.Close()
 		wg.Wait()
@@ -218,140 +222,120 @@ func main() {
 				result.ExitCode = 99
 			}
 
-			procLog.Printf("pipeline execution failed - %s\n", err)
+			if result.ExitCode == EXIT_CODE_CANCELED {
+				result.Error = "pipeline canceled"
+				procLog.Println("pipeline execution canceled")
+			} else {
+				procLog.Printf("pipeline execution failed - %s\n", err)
+			}
+			span.SetAttributes(attribute.Int("reeve.exit_code", result.ExitCode))
 		} else {
 			result.Success = true
 
 			procLog.Println("pipeline execution finished")
 		}
 
-		buffer = new(bytes.Buffer)
-		err = json.NewEncoder(buffer).Encode(result)
-		if err != nil {
-			procErrLog.Printf("encoding pipeline result failed - %s\n", err)
-			procLog.Printf("reconnecting in %v\n", retry)
-			time.Sleep(retry)
-			continue
-		}
-
-		req, err = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/v1/worker/result?group=%s&activity=%s", apiUrl, workerGroup, message.Activity), buffer)
-		if err != nil {
-			procErrLog.Printf("creating HTTP request failed - %s\n", err)
-			procLog.Printf("reconnecting in %v\n", retry)
-			time.Sleep(retry)
-			continue
-		}
-
-		req.Header.Set(authHeader, auth)
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err = client.Do(req)
+		err = api.SendWorkerResult(ctx, workerGroup, message.Activity, result)
 		if err != nil {
 			procErrLog.Printf("sending pipeline result failed - %s\n", err)
 			procLog.Printf("reconnecting in %v\n", retry)
+			health.Failed()
+			tracing.End(span, err)
 			time.Sleep(retry)
 			continue
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			errorMessage, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			procErrLog.Printf("sending pipeline result failed - status %v - %s\n", resp.StatusCode, string(errorMessage))
-			procLog.Printf("reconnecting in %v\n", retry)
-			time.Sleep(retry)
-			continue
-		}
-
-		resp.Body.Close()
+		tracing.End(span, nil)
 	}
 }
 
-func SendLogs(stream *stream.Stream, client *http.Client, authHeader, auth, apiUrl, workerGroup, activity string, procLog, errorLog *log.Logger) {
+func SendLogs(ctx context.Context, stream *stream.Stream, api *client.Client, workerGroup, activity string, procLog, errorLog *log.Logger) {
+	ctx, span := tracing.Start(ctx, "upload logs")
+	var uploadErr error
+	defer func() { tracing.End(span, uploadErr) }()
+
 	firstTry := true
 
 	for {
 		reader, err := stream.NextReader()
 		if err != nil {
-			errorLog.Printf("loading stdout stream reader failed - %s\n", err)
+			uploadErr = fmt.Errorf("loading stdout stream reader failed - %s", err)
+			errorLog.Println(uploadErr)
 			return
 		}
 
 		if firstTry {
 			firstTry = false
 		} else {
-			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v1/worker/logs?group=%s&activity=%s", apiUrl, workerGroup, activity), nil)
+			position, err := api.WorkerLogsPosition(ctx, workerGroup, activity)
 			if err != nil {
 				reader.Close()
-				errorLog.Printf("creating HTTP request failed - %s\n", err)
-				return
-			}
-
-			req.Header.Set(authHeader, auth)
-
-			resp, err := client.Do(req)
-			if err != nil {
-				reader.Close()
-				errorLog.Printf("fetching pipeline log position failed, retrying - %s\n", err)
-				continue
-			}
-
-			if resp.StatusCode != http.StatusOK {
-				reader.Close()
-				errorMessage, _ := io.ReadAll(resp.Body)
-				resp.Body.Close()
-				errorLog.Printf("fetching pipeline log position failed - status %v - %s\n", resp.StatusCode, string(errorMessage))
-				return
-			}
 
-			if resp.Header.Get("Content-Type") != "application/json" {
-				reader.Close()
-				resp.Body.Close()
-				errorLog.Println("fetching pipeline log position failed - Content-Type header is not application/json")
+				if retryable(err) {
+					errorLog.Printf("fetching pipeline log position failed, retrying in %v - %s\n", retry, err)
+					if !waitRetry(ctx) {
+						uploadErr = ctx.Err()
+						return
+					}
+					continue
+				}
+
+				uploadErr = fmt.Errorf("fetching pipeline log position failed - %s", err)
+				errorLog.Println(uploadErr)
 				return
 			}
 
-			var message schema.WorkerLogsPositionResponse
-			err = json.NewDecoder(resp.Body).Decode(&message)
-			resp.Body.Close()
-			if err != nil {
-				reader.Close()
-				errorLog.Printf("received invalid pipeline log position response - %s\n", err)
-				return
-			}
-
-			reader.Seek(message.Position, io.SeekStart)
+			reader.Seek(position, io.SeekStart)
 		}
 
-		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/v1/worker/logs?group=%s&activity=%s", apiUrl, workerGroup, activity), reader)
+		err = api.WriteWorkerLogs(ctx, workerGroup, activity, reader)
 		if err != nil {
-			reader.Close()
-			errorLog.Printf("creating HTTP request failed - %s\n", err)
-			return
-		}
-
-		req.Header.Set(authHeader, auth)
-
-		resp, err := client.Do(req)
-		if err != nil {
-			errorLog.Printf("sending pipeline logs failed, retrying - %s\n", err)
-			continue
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			errorMessage, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
+			if logLimitExceeded(err) {
+				// the server discards all remaining logs of this activity
+				procLog.Printf("log size limit exceeded, stopping log upload - %s\n", err)
+				return
+			}
 
-			if resp.StatusCode >= 500 {
-				errorLog.Printf("sending pipeline logs failed, retrying - status %v - %s\n", resp.StatusCode, string(errorMessage))
+			if retryable(err) {
+				errorLog.Printf("sending pipeline logs failed, retrying in %v - %s\n", retry, err)
+				if !waitRetry(ctx) {
+					uploadErr = ctx.Err()
+					return
+				}
 				continue
 			}
 
-			errorLog.Printf("sending pipeline logs failed - status %v - %s\n", resp.StatusCode, string(errorMessage))
+			uploadErr = fmt.Errorf("sending pipeline logs failed - %s", err)
+			errorLog.Println(uploadErr)
 			return
 		}
 
-		resp.Body.Close()
 		procLog.Println("done sending pipeline logs")
 		return
 	}
 }
+
+// waitRetry waits before retrying a failed request, it returns false if ctx is done in the meantime.
+func waitRetry(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(retry):
+		return true
+	}
+}
+
+// logLimitExceeded returns true if the server does not accept further logs of an activity.
+func logLimitExceeded(err error) bool {
+	var apiErr *client.Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusRequestEntityTooLarge
+}
+
+// retryable returns true for connection errors and server errors.
+func retryable(err error) bool {
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	return true
+}
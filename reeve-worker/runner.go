@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/reeveci/reeve/reeve-client"
+	"github.com/reeveci/reeve/reeve-common/tracing"
+)
+
+// CONTROL_PREFIX marks runner output lines which are handled by the worker instead of being logged.
+const CONTROL_PREFIX = "::reeve::"
+
+const CONTROL_APPROVAL = "approval"
+
+// EXIT_CODE_CANCELED is the exit code of the runner if the pipeline has been canceled.
+const EXIT_CODE_CANCELED = 3
+
+type ControlMessage struct {
+	Type     string                  `json:"type"`
+	Approval *client.ApprovalRequest `json:"approval,omitempty"`
+	Artifact *ArtifactChunk          `json:"artifact,omitempty"`
+}
+
+// RunPipeline runs the runner command, passing the pipeline on stdin and writing the runner output to output.
+// Control messages of the runner are handled until the runner exits.
+// Once shutdown is done, the runner is sent SIGTERM, so that it cancels the pipeline.
+func RunPipeline(ctx, shutdown context.Context, runnerCommand string, pipeline []byte, output io.Writer, api *client.Client, workerGroup, workerID, activity string, procLog, errorLog *log.Logger) error {
+	// artifact uploads continue after the runner exited
+	uploadCtx := ctx
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.Command(runnerCommand)
+	cmd.Env = append(os.Environ(), tracing.Env(ctx)...)
+	cmd.Env = append(cmd.Env, "REEVE_ACTIVITY="+activity, "REEVE_WORKER_ID="+workerID)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	// stdout and stderr share a pipe, so that the order of the runner output is kept
+	stdout, outputWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer stdout.Close()
+	cmd.Stdout = outputWriter
+	cmd.Stderr = outputWriter
+
+	err = cmd.Start()
+	outputWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-shutdown.Done():
+			procLog.Println("canceling pipeline execution")
+			err := cmd.Process.Signal(syscall.SIGTERM)
+			if err != nil {
+				errorLog.Printf("canceling pipeline execution failed - %s\n", err)
+			}
+		case <-exited:
+		}
+	}()
+
+	// stdin stays open, so that approval decisions can be sent after the pipeline
+	var stdinLock sync.Mutex
+	_, err = stdin.Write(pipeline)
+	if err != nil {
+		errorLog.Printf("sending pipeline to runner failed - %s\n", err)
+	}
+
+	var wg sync.WaitGroup
+
+	artifacts := make(ArtifactBuffers)
+	defer artifacts.Close()
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(line, CONTROL_PREFIX) {
+			var message ControlMessage
+			decodeErr := json.Unmarshal([]byte(strings.TrimPrefix(line, CONTROL_PREFIX)), &message)
+			if decodeErr != nil {
+				errorLog.Printf("invalid control message from runner - %s\n", decodeErr)
+			} else if message.Type == CONTROL_APPROVAL && message.Approval != nil {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					decision := RequestApproval(ctx, api, workerGroup, activity, *message.Approval, procLog, errorLog)
+					if decision == nil {
+						return
+					}
+
+					data, err := json.Marshal(decision)
+					if err != nil {
+						errorLog.Printf("encoding approval decision failed - %s\n", err)
+						return
+					}
+
+					stdinLock.Lock()
+					defer stdinLock.Unlock()
+
+					_, err = stdin.Write(append(data, '\n'))
+					if err != nil {
+						errorLog.Printf("sending approval decision to runner failed - %s\n", err)
+					}
+				}()
+			} else if message.Type == CONTROL_ARTIFACT && message.Artifact != nil {
+				path, err := artifacts.Add(*message.Artifact)
+				if err != nil {
+					errorLog.Println(err)
+				} else if path != "" {
+					name := message.Artifact.Name
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+
+						UploadArtifact(uploadCtx, api, workerGroup, activity, name, path, procLog, errorLog)
+					}()
+				}
+			} else {
+				errorLog.Printf("unknown control message type %s from runner\n", message.Type)
+			}
+		} else if line != "" {
+			_, writeErr := io.WriteString(output, line)
+			if writeErr != nil {
+				errorLog.Printf("writing runner output failed - %s\n", writeErr)
+			}
+		}
+
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				errorLog.Printf("reading runner output failed - %s\n", err)
+			}
+			break
+		}
+	}
+
+	err = cmd.Wait()
+	close(exited)
+
+	cancel()
+	wg.Wait()
+
+	return err
+}
+
+// RequestApproval blocks until the approval step has been decided on the server or ctx is done.
+func RequestApproval(ctx context.Context, api *client.Client, workerGroup, activity string, approval client.ApprovalRequest, procLog, errorLog *log.Logger) *client.ApprovalDecision {
+	ctx, span := tracing.Start(ctx, "wait for approval")
+
+	procLog.Printf("waiting for approval of step %s\n", approval.Name)
+
+	for {
+		decision, err := api.WorkerApproval(ctx, workerGroup, activity, approval)
+		if err == nil {
+			procLog.Printf("step %s has been decided by %s\n", approval.Name, decision.By)
+			tracing.End(span, nil)
+			return decision
+		}
+
+		if ctx.Err() != nil {
+			tracing.End(span, nil)
+			return nil
+		}
+
+		if !retryable(err) {
+			err = fmt.Errorf("waiting for approval failed - %s", err)
+			errorLog.Println(err)
+			tracing.End(span, err)
+			return nil
+		}
+
+		errorLog.Printf("waiting for approval failed, retrying in %v - %s\n", retry, err)
+
+		select {
+		case <-ctx.Done():
+			tracing.End(span, nil)
+			return nil
+		case <-time.After(retry):
+		}
+	}
+}
@@ -10,6 +10,19 @@ go get -u ./...
 go mod tidy
 cd ..
 
+echo visiting reeve-client
+cd reeve-client
+go get github.com/reeveci/reeve-lib
+go get -u ./...
+go mod tidy
+cd ..
+
+echo visiting reeve-common
+cd reeve-common
+go get -u ./...
+go mod tidy
+cd ..
+
 echo visiting reeve-worker
 cd reeve-worker
 go get github.com/reeveci/reeve-lib
@@ -31,6 +44,13 @@ go get -u ./...
 go mod tidy
 cd ..
 
+echo visiting reeve-cli
+cd reeve-cli
+go get github.com/reeveci/reeve-lib
+go get -u ./...
+go mod tidy
+cd ..
+
 echo visiting plugin-example
 cd plugin-example
 go get github.com/reeveci/reeve-lib
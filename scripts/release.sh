@@ -26,8 +26,17 @@ git checkout main
 
 echo "REEVE_VERSION=$VERSION">config.env
 scripts/cleanup.sh
+for module in reeve-server reeve-worker reeve-runner; do
+  (cd $module && go mod edit -require=github.com/reeveci/reeve/reeve-common@v$VERSION)
+done
+for module in reeve-worker reeve-cli; do
+  (cd $module && go mod edit -require=github.com/reeveci/reeve/reeve-client@v$VERSION)
+done
 git add . && git commit -m "release v$VERSION"
 git tag -a "v$VERSION" -m "v$VERSION"
+git tag -a "reeve-cli/v$VERSION" -m "v$VERSION"
+git tag -a "reeve-client/v$VERSION" -m "v$VERSION"
+git tag -a "reeve-common/v$VERSION" -m "v$VERSION"
 git tag -a "reeve-runner/v$VERSION" -m "v$VERSION"
 git tag -a "reeve-server/v$VERSION" -m "v$VERSION"
 git tag -a "reeve-tools/v$VERSION" -m "v$VERSION"